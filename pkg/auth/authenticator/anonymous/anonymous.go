@@ -0,0 +1,48 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package anonymous provides a request authenticator that never rejects
+// a request: anything reaching it authenticates as the system:anonymous
+// user, letting the authorizer decide whether the request is actually
+// permitted.
+package anonymous
+
+import (
+	"net/http"
+
+	"k8s.io/kubernetes/pkg/auth/authenticator"
+	"k8s.io/kubernetes/pkg/auth/user"
+)
+
+const (
+	// Username is the identity given to a request with no other
+	// authenticated identity.
+	Username = "system:anonymous"
+	// Group is the group every anonymous request belongs to, letting an
+	// authorizer grant or deny access to unauthenticated callers as a
+	// class.
+	Group = "system:unauthenticated"
+)
+
+// NewAuthenticator returns a request authenticator that always succeeds,
+// authenticating every request as Username in Group. It must be the
+// lowest-priority handler in a union authenticator so that any request
+// actually carrying credentials is authenticated by those instead.
+func NewAuthenticator() authenticator.Request {
+	return authenticator.RequestFunc(func(req *http.Request) (user.Info, bool, error) {
+		return &user.DefaultInfo{Name: Username, Groups: []string{Group}}, true, nil
+	})
+}
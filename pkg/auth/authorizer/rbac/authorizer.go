@@ -0,0 +1,186 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/auth/authorizer"
+	"k8s.io/kubernetes/pkg/serviceaccount"
+)
+
+// ClusterRoleGetter retrieves a single ClusterRole by name.
+type ClusterRoleGetter interface {
+	GetClusterRole(name string) (*ClusterRole, error)
+}
+
+// RoleGetter retrieves a single namespaced Role.
+type RoleGetter interface {
+	GetRole(namespace, name string) (*Role, error)
+}
+
+// ClusterRoleBindingLister lists every ClusterRoleBinding.
+type ClusterRoleBindingLister interface {
+	ListClusterRoleBindings() ([]*ClusterRoleBinding, error)
+}
+
+// RoleBindingLister lists the RoleBindings in a namespace.
+type RoleBindingLister interface {
+	ListRoleBindings(namespace string) ([]*RoleBinding, error)
+}
+
+// RBACAuthorizer is an authorizer.Authorizer that grants a request when
+// some RoleBinding or ClusterRoleBinding applicable to the requesting
+// user or one of its groups resolves, through its RoleRef, to a
+// PolicyRule that covers the request.
+type RBACAuthorizer struct {
+	roles               RoleGetter
+	clusterRoles        ClusterRoleGetter
+	roleBindings        RoleBindingLister
+	clusterRoleBindings ClusterRoleBindingLister
+}
+
+// New returns an RBACAuthorizer resolving bindings and roles through the
+// given lookups. Passing the same *Cache for all four is the common case.
+func New(roles RoleGetter, clusterRoles ClusterRoleGetter, roleBindings RoleBindingLister, clusterRoleBindings ClusterRoleBindingLister) *RBACAuthorizer {
+	return &RBACAuthorizer{
+		roles:               roles,
+		clusterRoles:        clusterRoles,
+		roleBindings:        roleBindings,
+		clusterRoleBindings: clusterRoleBindings,
+	}
+}
+
+// Authorize implements authorizer.Authorizer.
+func (r *RBACAuthorizer) Authorize(attrs authorizer.Attributes) error {
+	rules, err := r.applicableRules(attrs)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if ruleAllows(rule, attrs) {
+			return nil
+		}
+	}
+	return fmt.Errorf("RBAC: no Role or ClusterRole binding grants user %q access to resource %q in namespace %q",
+		attrs.GetUserName(), attrs.GetResource(), attrs.GetNamespace())
+}
+
+// applicableRules collects every PolicyRule granted, directly or through
+// a RoleRef, by a binding whose Subjects match attrs' user or groups.
+func (r *RBACAuthorizer) applicableRules(attrs authorizer.Attributes) ([]PolicyRule, error) {
+	var rules []PolicyRule
+
+	clusterBindings, err := r.clusterRoleBindings.ListClusterRoleBindings()
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range clusterBindings {
+		if !subjectsMatch(binding.Subjects, attrs) {
+			continue
+		}
+		clusterRole, err := r.clusterRoles.GetClusterRole(binding.RoleRef.Name)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, clusterRole.Rules...)
+	}
+
+	namespaceBindings, err := r.roleBindings.ListRoleBindings(attrs.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range namespaceBindings {
+		if !subjectsMatch(binding.Subjects, attrs) {
+			continue
+		}
+		switch binding.RoleRef.Kind {
+		case ClusterRoleKind:
+			clusterRole, err := r.clusterRoles.GetClusterRole(binding.RoleRef.Name)
+			if err != nil {
+				continue
+			}
+			rules = append(rules, clusterRole.Rules...)
+		case RoleKind:
+			role, err := r.roles.GetRole(binding.Namespace, binding.RoleRef.Name)
+			if err != nil {
+				continue
+			}
+			rules = append(rules, role.Rules...)
+		}
+	}
+
+	return rules, nil
+}
+
+// subjectsMatch reports whether any of subjects names the user or a group
+// attrs belongs to.
+func subjectsMatch(subjects []Subject, attrs authorizer.Attributes) bool {
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case UserKind:
+			if subject.Name == attrs.GetUserName() {
+				return true
+			}
+		case ServiceAccountKind:
+			if serviceaccount.MakeUsername(subject.Namespace, subject.Name) == attrs.GetUserName() {
+				return true
+			}
+		case GroupKind:
+			for _, group := range attrs.GetGroups() {
+				if group == subject.Name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ruleAllows reports whether rule covers attrs' resource and verb.
+// attrs only exposes IsReadOnly rather than a concrete verb, so a
+// read-only request is allowed by any rule naming a read verb (or "*"),
+// and a mutating request by any rule naming a write verb (or "*").
+func ruleAllows(rule PolicyRule, attrs authorizer.Attributes) bool {
+	if !stringsMatch(rule.Resources, attrs.GetResource()) {
+		return false
+	}
+	if attrs.IsReadOnly() {
+		return verbsInclude(rule.Verbs, "get", "list", "watch", "*")
+	}
+	return verbsInclude(rule.Verbs, "create", "update", "patch", "delete", "*")
+}
+
+func stringsMatch(values []string, target string) bool {
+	for _, v := range values {
+		if v == "*" || v == target {
+			return true
+		}
+	}
+	return false
+}
+
+func verbsInclude(verbs []string, anyOf ...string) bool {
+	for _, v := range verbs {
+		for _, want := range anyOf {
+			if v == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import "k8s.io/kubernetes/pkg/api"
+
+const (
+	// ClusterRoleClusterAdmin can perform every action on every resource.
+	ClusterRoleClusterAdmin = "cluster-admin"
+	// ClusterRoleEdit can read and modify most resources.
+	ClusterRoleEdit = "edit"
+	// ClusterRoleView can read most resources, but not modify them.
+	ClusterRoleView = "view"
+	// ClusterRoleDiscovery grants no permissions; it is bound to every
+	// namespace's "default" ServiceAccount as an explicit placeholder,
+	// so a future discovery-style permission can be added here without
+	// also having to touch every namespace's bindings.
+	ClusterRoleDiscovery = "system:discovery"
+)
+
+// bootstrapClusterRoles returns the built-in ClusterRoles every cluster
+// starts with.
+func bootstrapClusterRoles() []*ClusterRole {
+	return []*ClusterRole{
+		{
+			ObjectMeta: api.ObjectMeta{Name: ClusterRoleClusterAdmin},
+			Rules:      []PolicyRule{{Verbs: []string{"*"}, Resources: []string{"*"}}},
+		},
+		{
+			ObjectMeta: api.ObjectMeta{Name: ClusterRoleEdit},
+			Rules:      []PolicyRule{{Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}, Resources: []string{"*"}}},
+		},
+		{
+			ObjectMeta: api.ObjectMeta{Name: ClusterRoleView},
+			Rules:      []PolicyRule{{Verbs: []string{"get", "list", "watch"}, Resources: []string{"*"}}},
+		},
+		{
+			ObjectMeta: api.ObjectMeta{Name: ClusterRoleDiscovery},
+			Rules:      []PolicyRule{},
+		},
+	}
+}
+
+// defaultServiceAccountRoleBinding returns the RoleBinding that seeds
+// namespace's "default" ServiceAccount with ClusterRoleDiscovery.
+func defaultServiceAccountRoleBinding(namespace string) *RoleBinding {
+	return &RoleBinding{
+		ObjectMeta: api.ObjectMeta{Name: "system:serviceaccount:default", Namespace: namespace},
+		Subjects:   []Subject{{Kind: ServiceAccountKind, Namespace: namespace, Name: "default"}},
+		RoleRef:    RoleRef{Kind: ClusterRoleKind, Name: ClusterRoleDiscovery},
+	}
+}
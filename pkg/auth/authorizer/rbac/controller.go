@@ -0,0 +1,111 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	clientset "k8s.io/kubernetes/pkg/client/clientset_generated/release_1_2"
+)
+
+// defaultReconcilePeriod is how often the controller re-scans namespaces
+// to make sure every one still has its default bindings, when
+// BootstrapControllerOptions doesn't override it.
+const defaultReconcilePeriod = 1 * time.Minute
+
+// BootstrapControllerOptions configures a BootstrapController.
+type BootstrapControllerOptions struct {
+	// Store is where the bootstrap ClusterRoles and RoleBindings are
+	// written.
+	Store *Store
+	// ReconcilePeriod overrides defaultReconcilePeriod; tests use a
+	// short period so a namespace created mid-test gets its default
+	// binding without the full production interval elapsing.
+	ReconcilePeriod time.Duration
+}
+
+// NewBootstrapController returns a controller that seeds store with the
+// built-in cluster-admin/edit/view ClusterRoles once, and then
+// continually reconciles a default-SA RoleBinding into every namespace
+// it observes through cl.
+func NewBootstrapController(cl clientset.Interface, options BootstrapControllerOptions) *BootstrapController {
+	period := options.ReconcilePeriod
+	if period <= 0 {
+		period = defaultReconcilePeriod
+	}
+	return &BootstrapController{
+		client:          cl,
+		store:           options.Store,
+		reconcilePeriod: period,
+	}
+}
+
+// BootstrapController seeds and reconciles the built-in bootstrap policy.
+type BootstrapController struct {
+	client          clientset.Interface
+	store           *Store
+	reconcilePeriod time.Duration
+
+	stopChan chan struct{}
+}
+
+// Run seeds the bootstrap ClusterRoles immediately and starts the
+// namespace reconciliation loop. It returns immediately.
+func (c *BootstrapController) Run() {
+	for _, clusterRole := range bootstrapClusterRoles() {
+		c.store.SetClusterRole(clusterRole)
+	}
+
+	c.stopChan = make(chan struct{})
+	c.reconcileNamespaces()
+	go c.loop()
+}
+
+// Stop halts the reconciliation loop.
+func (c *BootstrapController) Stop() {
+	close(c.stopChan)
+}
+
+func (c *BootstrapController) loop() {
+	ticker := time.NewTicker(c.reconcilePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.reconcileNamespaces()
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// reconcileNamespaces makes sure every namespace the API server knows
+// about has a RoleBinding seeding its "default" ServiceAccount with
+// ClusterRoleDiscovery.
+func (c *BootstrapController) reconcileNamespaces() {
+	namespaces, err := c.client.Legacy().Namespaces().List(api.ListOptions{})
+	if err != nil {
+		glog.Errorf("could not list namespaces for RBAC bootstrap reconciliation: %v", err)
+		return
+	}
+	for _, ns := range namespaces.Items {
+		c.store.SetRoleBinding(defaultServiceAccountRoleBinding(ns.Name))
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Store holds the authoritative Role/ClusterRole/RoleBinding/
+// ClusterRoleBinding objects. There is no backing API registry for these
+// types in this tree, so Store is the system of record: BootstrapController
+// writes the bootstrap objects into it, and anything else (tests,
+// administrators) mutates it directly through Set/Delete.
+type Store struct {
+	mu                  sync.RWMutex
+	clusterRoles        map[string]*ClusterRole
+	roles               map[string]*Role            // keyed by namespace/name
+	clusterRoleBindings map[string]*ClusterRoleBinding
+	roleBindings        map[string]*RoleBinding      // keyed by namespace/name
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{
+		clusterRoles:        map[string]*ClusterRole{},
+		roles:                map[string]*Role{},
+		clusterRoleBindings: map[string]*ClusterRoleBinding{},
+		roleBindings:        map[string]*RoleBinding{},
+	}
+}
+
+func roleKey(namespace, name string) string { return namespace + "/" + name }
+
+// SetClusterRole creates or replaces a ClusterRole.
+func (s *Store) SetClusterRole(r *ClusterRole) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clusterRoles[r.Name] = r
+}
+
+// SetRole creates or replaces a Role.
+func (s *Store) SetRole(r *Role) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles[roleKey(r.Namespace, r.Name)] = r
+}
+
+// SetClusterRoleBinding creates or replaces a ClusterRoleBinding.
+func (s *Store) SetClusterRoleBinding(b *ClusterRoleBinding) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clusterRoleBindings[b.Name] = b
+}
+
+// SetRoleBinding creates or replaces a RoleBinding.
+func (s *Store) SetRoleBinding(b *RoleBinding) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roleBindings[roleKey(b.Namespace, b.Name)] = b
+}
+
+// DeleteRoleBinding removes the named RoleBinding, if present.
+func (s *Store) DeleteRoleBinding(namespace, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.roleBindings, roleKey(namespace, name))
+}
+
+// GetClusterRole implements ClusterRoleGetter.
+func (s *Store) GetClusterRole(name string) (*ClusterRole, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, ok := s.clusterRoles[name]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("clusterrole %q not found", name)
+}
+
+// GetRole implements RoleGetter.
+func (s *Store) GetRole(namespace, name string) (*Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, ok := s.roles[roleKey(namespace, name)]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("role %q not found in namespace %q", name, namespace)
+}
+
+// ListClusterRoleBindings implements ClusterRoleBindingLister.
+func (s *Store) ListClusterRoleBindings() ([]*ClusterRoleBinding, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*ClusterRoleBinding, 0, len(s.clusterRoleBindings))
+	for _, b := range s.clusterRoleBindings {
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// ListRoleBindings implements RoleBindingLister.
+func (s *Store) ListRoleBindings(namespace string) ([]*RoleBinding, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []*RoleBinding{}
+	for _, b := range s.roleBindings {
+		if b.Namespace == namespace {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// snapshot is an immutable copy of the Store's contents, taken on a
+// resync tick.
+type snapshot struct {
+	clusterRoles        map[string]*ClusterRole
+	roles               map[string]*Role
+	clusterRoleBindings map[string]*ClusterRoleBinding
+	roleBindings        map[string]*RoleBinding
+}
+
+func (s *Store) takeSnapshot() *snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap := &snapshot{
+		clusterRoles:        make(map[string]*ClusterRole, len(s.clusterRoles)),
+		roles:               make(map[string]*Role, len(s.roles)),
+		clusterRoleBindings: make(map[string]*ClusterRoleBinding, len(s.clusterRoleBindings)),
+		roleBindings:        make(map[string]*RoleBinding, len(s.roleBindings)),
+	}
+	for k, v := range s.clusterRoles {
+		snap.clusterRoles[k] = v
+	}
+	for k, v := range s.roles {
+		snap.roles[k] = v
+	}
+	for k, v := range s.clusterRoleBindings {
+		snap.clusterRoleBindings[k] = v
+	}
+	for k, v := range s.roleBindings {
+		snap.roleBindings[k] = v
+	}
+	return snap
+}
+
+// Cache is a read-only, periodically refreshed view of a Store, standing
+// in for the SharedInformer cache RBACAuthorizer would read from in a
+// real apiserver: a change written to Store only becomes visible through
+// Cache once the next resync tick copies it over, so an authorization
+// decision driven off a binding edit can lag by up to one resyncPeriod.
+type Cache struct {
+	store        *Store
+	resyncPeriod time.Duration
+
+	mu   sync.RWMutex
+	snap *snapshot
+
+	stopChan chan struct{}
+}
+
+// NewCache returns a Cache reading from store and refreshing every
+// resyncPeriod. The first snapshot is taken immediately.
+func NewCache(store *Store, resyncPeriod time.Duration) *Cache {
+	c := &Cache{store: store, resyncPeriod: resyncPeriod}
+	c.snap = store.takeSnapshot()
+	return c
+}
+
+// Run starts the periodic resync loop. It returns immediately.
+func (c *Cache) Run() {
+	c.stopChan = make(chan struct{})
+	go c.loop()
+}
+
+// Stop halts the resync loop.
+func (c *Cache) Stop() {
+	close(c.stopChan)
+}
+
+func (c *Cache) loop() {
+	ticker := time.NewTicker(c.resyncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.resync()
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+func (c *Cache) resync() {
+	snap := c.store.takeSnapshot()
+	c.mu.Lock()
+	c.snap = snap
+	c.mu.Unlock()
+}
+
+func (c *Cache) currentSnapshot() *snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snap
+}
+
+// GetClusterRole implements ClusterRoleGetter against the last resync.
+func (c *Cache) GetClusterRole(name string) (*ClusterRole, error) {
+	if r, ok := c.currentSnapshot().clusterRoles[name]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("clusterrole %q not found", name)
+}
+
+// GetRole implements RoleGetter against the last resync.
+func (c *Cache) GetRole(namespace, name string) (*Role, error) {
+	if r, ok := c.currentSnapshot().roles[roleKey(namespace, name)]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("role %q not found in namespace %q", name, namespace)
+}
+
+// ListClusterRoleBindings implements ClusterRoleBindingLister against the
+// last resync.
+func (c *Cache) ListClusterRoleBindings() ([]*ClusterRoleBinding, error) {
+	snap := c.currentSnapshot()
+	out := make([]*ClusterRoleBinding, 0, len(snap.clusterRoleBindings))
+	for _, b := range snap.clusterRoleBindings {
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// ListRoleBindings implements RoleBindingLister against the last resync.
+func (c *Cache) ListRoleBindings(namespace string) ([]*RoleBinding, error) {
+	snap := c.currentSnapshot()
+	out := []*RoleBinding{}
+	for _, b := range snap.roleBindings {
+		if b.Namespace == namespace {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
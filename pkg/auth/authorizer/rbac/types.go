@@ -0,0 +1,100 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rbac implements a Role-Based Access Control authorizer: Roles
+// and ClusterRoles hold PolicyRules, and RoleBindings/ClusterRoleBindings
+// grant those rules to Subjects (users, groups, or ServiceAccounts).
+package rbac
+
+import "k8s.io/kubernetes/pkg/api"
+
+// PolicyRule describes what a Role or ClusterRole allows, independent of
+// who it's granted to or which namespace it's bound in.
+type PolicyRule struct {
+	// Verbs this rule allows, e.g. "get", "list", "watch", "create",
+	// "update", "patch", "delete". "*" matches every verb.
+	Verbs []string
+	// Resources this rule applies to, e.g. "pods", "secrets". "*"
+	// matches every resource.
+	Resources []string
+}
+
+// Role is a namespaced set of permissions.
+type Role struct {
+	api.TypeMeta
+	api.ObjectMeta
+
+	Rules []PolicyRule
+}
+
+// ClusterRole is a cluster-scoped set of permissions; it can be bound
+// either cluster-wide (ClusterRoleBinding) or within a single namespace
+// (RoleBinding), unlike Role which only ever binds within its own
+// namespace.
+type ClusterRole struct {
+	api.TypeMeta
+	api.ObjectMeta
+
+	Rules []PolicyRule
+}
+
+// Subject constants for Subject.Kind.
+const (
+	UserKind           = "User"
+	GroupKind          = "Group"
+	ServiceAccountKind = "ServiceAccount"
+)
+
+// Subject identifies a user, group, or ServiceAccount a binding applies
+// to.
+type Subject struct {
+	Kind string
+	Name string
+	// Namespace is only meaningful when Kind is ServiceAccountKind.
+	Namespace string
+}
+
+// RoleRef constants for RoleRef.Kind.
+const (
+	RoleKind        = "Role"
+	ClusterRoleKind = "ClusterRole"
+)
+
+// RoleRef references the Role or ClusterRole a binding grants.
+type RoleRef struct {
+	Kind string
+	Name string
+}
+
+// RoleBinding grants the permissions in RoleRef to Subjects, scoped to
+// the binding's own namespace even when RoleRef points at a ClusterRole.
+type RoleBinding struct {
+	api.TypeMeta
+	api.ObjectMeta
+
+	Subjects []Subject
+	RoleRef  RoleRef
+}
+
+// ClusterRoleBinding grants the permissions in RoleRef to Subjects,
+// cluster-wide. RoleRef must point at a ClusterRole.
+type ClusterRoleBinding struct {
+	api.TypeMeta
+	api.ObjectMeta
+
+	Subjects []Subject
+	RoleRef  RoleRef
+}
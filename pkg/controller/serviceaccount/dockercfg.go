@@ -0,0 +1,197 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serviceaccount
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/client/cache"
+	clientset "k8s.io/kubernetes/pkg/client/clientset_generated/release_1_2"
+	"k8s.io/kubernetes/pkg/controller/framework"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+// dockercfgResyncPeriod is how often the DockercfgController relists
+// ServiceAccounts to catch any it may have missed via watch.
+const dockercfgResyncPeriod = 5 * time.Minute
+
+// DockercfgProvider supplies the registry credential blob that backs the
+// dockercfg secret DockercfgController creates for each ServiceAccount. It
+// is consulted per-namespace so cluster operators can hand out different
+// registry credentials to different namespaces; a cluster-wide source just
+// ignores the namespace argument.
+type DockercfgProvider interface {
+	// Get returns the raw ".dockercfg"-style JSON blob to store in the
+	// kubernetes.io/dockercfg secret created for ServiceAccounts in
+	// namespace.
+	Get(namespace string) (string, error)
+}
+
+// StaticDockercfgProvider returns the same dockercfg blob for every
+// namespace. It exists primarily for tests and for the simple case of a
+// single cluster-wide registry credential.
+type StaticDockercfgProvider struct {
+	// Dockercfg is the ".dockercfg"-style JSON blob returned verbatim for
+	// every namespace.
+	Dockercfg string
+}
+
+func (p StaticDockercfgProvider) Get(namespace string) (string, error) {
+	return p.Dockercfg, nil
+}
+
+// DockercfgControllerOptions contains options for the DockercfgController.
+type DockercfgControllerOptions struct {
+	// DockercfgProvider supplies the credential blob stored in the secrets
+	// this controller creates.
+	DockercfgProvider DockercfgProvider
+}
+
+// NewDockercfgController returns a new *DockercfgController.
+func NewDockercfgController(cl clientset.Interface, options DockercfgControllerOptions) *DockercfgController {
+	e := &DockercfgController{
+		client:   cl,
+		provider: options.DockercfgProvider,
+	}
+
+	e.serviceAccounts, e.serviceAccountController = framework.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options api.ListOptions) (runtime.Object, error) {
+				return e.client.Legacy().ServiceAccounts(api.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options api.ListOptions) (watch.Interface, error) {
+				return e.client.Legacy().ServiceAccounts(api.NamespaceAll).Watch(options)
+			},
+		},
+		&api.ServiceAccount{},
+		dockercfgResyncPeriod,
+		framework.ResourceEventHandlerFuncs{
+			AddFunc:    e.serviceAccountAdded,
+			UpdateFunc: e.serviceAccountUpdated,
+		},
+	)
+
+	return e
+}
+
+// DockercfgController provisions and maintains a kubernetes.io/dockercfg
+// secret for every ServiceAccount, appending it to the ServiceAccount's
+// ImagePullSecrets. It mirrors TokensController's job, but for the
+// per-ServiceAccount image pull secret rather than the API token secret.
+type DockercfgController struct {
+	client   clientset.Interface
+	provider DockercfgProvider
+
+	serviceAccounts           cache.Store
+	serviceAccountController *framework.Controller
+
+	stopChan chan struct{}
+}
+
+// Run starts the informer that watches ServiceAccounts. It returns
+// immediately; work happens on background goroutines until Stop is called.
+func (e *DockercfgController) Run() {
+	e.stopChan = make(chan struct{})
+	go e.serviceAccountController.Run(e.stopChan)
+}
+
+// Stop halts the DockercfgController's informer.
+func (e *DockercfgController) Stop() {
+	close(e.stopChan)
+}
+
+func (e *DockercfgController) serviceAccountAdded(obj interface{}) {
+	serviceAccount := obj.(*api.ServiceAccount)
+	if err := e.ensureDockercfgSecret(serviceAccount); err != nil {
+		glog.Errorf("error provisioning dockercfg secret for service account %s/%s: %v", serviceAccount.Namespace, serviceAccount.Name, err)
+	}
+}
+
+func (e *DockercfgController) serviceAccountUpdated(oldObj, newObj interface{}) {
+	e.serviceAccountAdded(newObj)
+}
+
+// ensureDockercfgSecret makes sure serviceAccount references a
+// kubernetes.io/dockercfg secret, creating one from the configured
+// DockercfgProvider and appending it to ImagePullSecrets if necessary.
+func (e *DockercfgController) ensureDockercfgSecret(serviceAccount *api.ServiceAccount) error {
+	if e.hasDockercfgSecret(serviceAccount) {
+		return nil
+	}
+
+	dockercfg, err := e.provider.Get(serviceAccount.Namespace)
+	if err != nil {
+		return fmt.Errorf("could not get dockercfg for namespace %s: %v", serviceAccount.Namespace, err)
+	}
+
+	secret := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-dockercfg-", serviceAccount.Name),
+			Namespace:    serviceAccount.Namespace,
+			Annotations: map[string]string{
+				api.ServiceAccountNameKey: serviceAccount.Name,
+				api.ServiceAccountUIDKey:  string(serviceAccount.UID),
+			},
+		},
+		Type: api.SecretTypeDockercfg,
+		Data: map[string][]byte{
+			api.DockerConfigKey: []byte(dockercfg),
+		},
+	}
+	createdSecret, err := e.client.Legacy().Secrets(serviceAccount.Namespace).Create(secret)
+	if err != nil {
+		return fmt.Errorf("could not create dockercfg secret: %v", err)
+	}
+
+	// Re-fetch to avoid clobbering concurrent edits (e.g. the token
+	// controller adding the API token secret to the same object).
+	liveServiceAccount, err := e.client.Legacy().ServiceAccounts(serviceAccount.Namespace).Get(serviceAccount.Name)
+	if err != nil {
+		return err
+	}
+	liveServiceAccount.Secrets = append(liveServiceAccount.Secrets, api.ObjectReference{Name: createdSecret.Name})
+	liveServiceAccount.ImagePullSecrets = append(liveServiceAccount.ImagePullSecrets, api.LocalObjectReference{Name: createdSecret.Name})
+	if _, err := e.client.Legacy().ServiceAccounts(serviceAccount.Namespace).Update(liveServiceAccount); err != nil {
+		// Someone else may have already provisioned one; clean up ours.
+		delErr := e.client.Legacy().Secrets(serviceAccount.Namespace).Delete(createdSecret.Name, nil)
+		if delErr != nil && !apierrors.IsNotFound(delErr) {
+			glog.Errorf("error cleaning up orphaned dockercfg secret %s/%s: %v", serviceAccount.Namespace, createdSecret.Name, delErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (e *DockercfgController) hasDockercfgSecret(serviceAccount *api.ServiceAccount) bool {
+	for _, ref := range serviceAccount.Secrets {
+		secret, err := e.client.Legacy().Secrets(serviceAccount.Namespace).Get(ref.Name)
+		if err != nil {
+			continue
+		}
+		if secret.Type == api.SecretTypeDockercfg {
+			return true
+		}
+	}
+	return false
+}
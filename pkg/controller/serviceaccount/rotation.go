@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serviceaccount
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	clientset "k8s.io/kubernetes/pkg/client/clientset_generated/release_1_2"
+	"k8s.io/kubernetes/pkg/serviceaccount"
+)
+
+// tokenRotationCheckPeriod is how often the rotation loop re-scans token
+// secrets for a stale "kid" annotation.
+const tokenRotationCheckPeriod = 1 * time.Minute
+
+// TokenRotationControllerOptions configures a TokenRotationController.
+type TokenRotationControllerOptions struct {
+	// KeyProvider supplies the set of key IDs that are still trusted for
+	// verification. Token secrets signed by a kid outside that set are
+	// deleted so TokensController recreates them with the active key.
+	KeyProvider serviceaccount.KeyProvider
+}
+
+// NewTokenRotationController returns a controller that proactively deletes
+// ServiceAccount token secrets whose signing key has fallen out of the
+// active trust set, forcing TokensController to reissue them with a
+// currently trusted key. Without this, a token signed before a rollover
+// keeps working only until something else happens to delete its secret.
+func NewTokenRotationController(cl clientset.Interface, options TokenRotationControllerOptions) *TokenRotationController {
+	return &TokenRotationController{
+		client:      cl,
+		keyProvider: options.KeyProvider,
+	}
+}
+
+// TokenRotationController periodically scans ServiceAccount token secrets
+// and deletes any whose "kid" annotation is no longer trusted.
+type TokenRotationController struct {
+	client      clientset.Interface
+	keyProvider serviceaccount.KeyProvider
+
+	stopChan chan struct{}
+}
+
+// Run starts the rotation scan loop. It returns immediately.
+func (c *TokenRotationController) Run() {
+	c.stopChan = make(chan struct{})
+	go c.loop()
+}
+
+// Stop halts the rotation scan loop.
+func (c *TokenRotationController) Stop() {
+	close(c.stopChan)
+}
+
+func (c *TokenRotationController) loop() {
+	ticker := time.NewTicker(tokenRotationCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.rotateStaleTokens()
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// rotateStaleTokens deletes every token secret signed by a kid the
+// KeyProvider no longer trusts.
+func (c *TokenRotationController) rotateStaleTokens() {
+	trustedKeys, err := c.keyProvider.PublicKeys()
+	if err != nil {
+		glog.Errorf("could not list trusted keys for token rotation: %v", err)
+		return
+	}
+
+	secrets, err := c.client.Legacy().Secrets(api.NamespaceAll).List(api.ListOptions{})
+	if err != nil {
+		glog.Errorf("could not list secrets for token rotation: %v", err)
+		return
+	}
+
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.Type != api.SecretTypeServiceAccountToken {
+			continue
+		}
+		kid := secret.Annotations[TokenSigningKeyIDAnnotation]
+		if len(kid) == 0 {
+			continue
+		}
+		if _, trusted := trustedKeys[kid]; trusted {
+			continue
+		}
+		if err := c.client.Legacy().Secrets(secret.Namespace).Delete(secret.Name, nil); err != nil {
+			glog.Errorf("error rotating stale token secret %s/%s (kid %s): %v", secret.Namespace, secret.Name, kid, err)
+			continue
+		}
+		glog.V(2).Infof("rotated token secret %s/%s: kid %s no longer trusted", secret.Namespace, secret.Name, kid)
+	}
+}
+
+// TokenSigningKeyIDAnnotation records the kid used to sign a ServiceAccount
+// token secret, so TokenRotationController can tell whether it is stale
+// without re-parsing the JWT. TokensController must stamp this annotation
+// with the keyID serviceaccount.TokenGenerator.GenerateToken returns when
+// it mints the secret; without it every secret looks unsigned and
+// rotateStaleTokens has nothing to match against.
+const TokenSigningKeyIDAnnotation = "kubernetes.io/serviceaccount-token.kid"
@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockershim
+
+import (
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+	"k8s.io/kubernetes/pkg/kubelet/dockertools"
+	"k8s.io/kubernetes/pkg/types"
+	"k8s.io/kubernetes/pkg/util"
+)
+
+// DockerService implements RuntimeService and ImageService on top of a DockerManager,
+// translating each CRI-style call into the existing DockerManager method that already
+// does the work.
+type DockerService struct {
+	dm *dockertools.DockerManager
+}
+
+var _ RuntimeService = &DockerService{}
+var _ ImageService = &DockerService{}
+
+// NewDockerService returns a DockerService backed by dm.
+func NewDockerService(dm *dockertools.DockerManager) *DockerService {
+	return &DockerService{dm: dm}
+}
+
+func (s *DockerService) RunPodSandbox(config *PodSandboxConfig) (string, error) {
+	id, err := s.dm.RunPodSandbox(config.Pod)
+	return string(id), err
+}
+
+func (s *DockerService) StopPodSandbox(pod *api.Pod, sandboxID kubecontainer.ContainerID) error {
+	return s.dm.StopPodSandbox(pod, sandboxID)
+}
+
+func (s *DockerService) PodSandboxStatus(pod *api.Pod, sandboxID kubecontainer.ContainerID) (*PodSandboxStatus, error) {
+	// The IP address dm.PodSandboxStatus also returns is reported to CRI callers through
+	// kubecontainer.PodStatus.IP (via PodStatus below) rather than duplicated here.
+	status, _, err := s.dm.PodSandboxStatus(sandboxID, pod.Name, pod.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	netNS, err := s.dm.GetNetNS(sandboxID)
+	if err != nil {
+		netNS = ""
+	}
+	return &PodSandboxStatus{
+		ID:        status.ID.ID,
+		NetNS:     netNS,
+		CreatedAt: status.CreatedAt.Unix(),
+	}, nil
+}
+
+func (s *DockerService) StartContainer(config *ContainerConfig, sandboxID kubecontainer.DockerID, restartCount int) (kubecontainer.ContainerID, error) {
+	return s.dm.StartContainer(config.Pod, config.Container, sandboxID, config.PullSecrets, restartCount)
+}
+
+func (s *DockerService) StopContainer(containerID kubecontainer.ContainerID, pod *api.Pod, container *api.Container, message string) error {
+	return s.dm.KillContainerInPod(containerID, container, pod, message, nil)
+}
+
+func (s *DockerService) ContainerStatus(containerID string, podName, podNamespace string) (*ContainerStatus, error) {
+	status, _, err := s.dm.InspectContainer(containerID, podName, podNamespace)
+	return status, err
+}
+
+func (s *DockerService) SyncPod(pod *api.Pod, apiPodStatus api.PodStatus, podStatus *kubecontainer.PodStatus, pullSecrets []api.Secret, backOff *util.Backoff, options kubecontainer.SyncPodOptions) kubecontainer.PodSyncResult {
+	return s.dm.SyncPod(pod, apiPodStatus, podStatus, pullSecrets, backOff, options)
+}
+
+func (s *DockerService) PodStatus(uid types.UID, name, namespace string) (*kubecontainer.PodStatus, error) {
+	return s.dm.GetPodStatus(uid, name, namespace)
+}
+
+func (s *DockerService) PullImage(image kubecontainer.ImageSpec, secrets []api.Secret) error {
+	return s.dm.PullImage(image, secrets)
+}
+
+func (s *DockerService) ImageStatus(image kubecontainer.ImageSpec) (bool, error) {
+	return s.dm.IsImagePresent(image)
+}
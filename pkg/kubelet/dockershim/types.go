@@ -0,0 +1,97 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dockershim implements a CRI (Container Runtime Interface)-shaped RuntimeService
+// and ImageService in front of dockertools.DockerManager, so the kubelet's sync loop can be
+// expressed as a set of narrow RPC-style handlers instead of calling DockerManager directly,
+// as it would for any other CRI runtime.
+//
+// The types in this file stand in for what would, on the wire, be protobuf messages
+// generated from a runtime.proto (see external doc 6). This package has no gRPC transport
+// (this tree carries no grpc/protobuf dependency) and no client wrapper type: DockerService's
+// methods are called directly, in-process.
+package dockershim
+
+import (
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+	"k8s.io/kubernetes/pkg/types"
+	"k8s.io/kubernetes/pkg/util"
+)
+
+// PodSandboxConfig mirrors the CRI PodSandboxConfig message: everything RunPodSandbox needs
+// to create the pod's infra container and set up its network namespace.
+type PodSandboxConfig struct {
+	Pod *api.Pod
+}
+
+// PodSandboxStatus mirrors the CRI PodSandboxStatus message.
+type PodSandboxStatus struct {
+	ID        string
+	NetNS     string
+	CreatedAt int64
+}
+
+// ContainerConfig mirrors the CRI ContainerConfig message: everything StartContainer needs
+// to create and start an app container inside an already-running sandbox.
+type ContainerConfig struct {
+	Pod         *api.Pod
+	Container   *api.Container
+	PullSecrets []api.Secret
+}
+
+// ContainerStatus mirrors the CRI ContainerStatus message. It is intentionally just
+// kubecontainer.ContainerStatus: that type already has the shape CRI's message does
+// (ID, state, image, exit code, reason), so no separate translation struct is needed.
+type ContainerStatus = kubecontainer.ContainerStatus
+
+// RuntimeService is the subset of the CRI RuntimeService this shim implements, covering pod
+// sandbox and container lifecycle plus the whole-pod sync path the kubelet drives today.
+type RuntimeService interface {
+	// RunPodSandbox creates and starts a pod sandbox (the pod infra container plus its
+	// network namespace), returning the sandbox ID.
+	RunPodSandbox(config *PodSandboxConfig) (string, error)
+	// StopPodSandbox tears down a sandbox's network namespace and stops its infra container.
+	StopPodSandbox(pod *api.Pod, sandboxID kubecontainer.ContainerID) error
+	// PodSandboxStatus returns the status of the sandbox identified by sandboxID.
+	PodSandboxStatus(pod *api.Pod, sandboxID kubecontainer.ContainerID) (*PodSandboxStatus, error)
+
+	// StartContainer creates and starts an app container joined to sandboxID's namespaces,
+	// returning the container's ID.
+	StartContainer(config *ContainerConfig, sandboxID kubecontainer.DockerID, restartCount int) (kubecontainer.ContainerID, error)
+	// StopContainer stops a running container.
+	StopContainer(containerID kubecontainer.ContainerID, pod *api.Pod, container *api.Container, message string) error
+	// ContainerStatus returns the status of the container identified by containerID.
+	ContainerStatus(containerID string, podName, podNamespace string) (*ContainerStatus, error)
+
+	// SyncPod reconciles a pod's sandbox and containers to match its spec. This is the
+	// whole-pod entry point the kubelet's sync loop drives today; RunPodSandbox/
+	// StartContainer/StopContainer above are the narrower handlers it's built from.
+	// options.UpdateType == kubecontainer.SyncPodKill takes a fast path straight to killing
+	// the pod's containers, honoring options.GracePeriodOverride if set.
+	SyncPod(pod *api.Pod, apiPodStatus api.PodStatus, podStatus *kubecontainer.PodStatus, pullSecrets []api.Secret, backOff *util.Backoff, options kubecontainer.SyncPodOptions) kubecontainer.PodSyncResult
+	// PodStatus returns the aggregate status (sandbox plus containers) of the named pod.
+	PodStatus(uid types.UID, name, namespace string) (*kubecontainer.PodStatus, error)
+}
+
+// ImageService is the subset of the CRI ImageService this shim implements, mirroring
+// DockerManager's existing kubecontainer.ImageService-shaped methods.
+type ImageService interface {
+	// PullImage pulls image, authenticating with secrets.
+	PullImage(image kubecontainer.ImageSpec, secrets []api.Secret) error
+	// ImageStatus reports whether image is already present locally.
+	ImageStatus(image kubecontainer.ImageSpec) (bool, error)
+}
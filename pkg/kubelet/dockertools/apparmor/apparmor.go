@@ -0,0 +1,159 @@
+// +build linux
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package apparmor resolves and loads the AppArmor profile a container
+// should run under, mirroring the annotation-driven model CRI-O's
+// server/apparmor subsystem uses.
+package apparmor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// ContainerAnnotationKeyPrefix is prepended to a container name to
+	// form the pod annotation key carrying its AppArmor profile, e.g.
+	// "container.apparmor.security.beta.kubernetes.io/<name>".
+	ContainerAnnotationKeyPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+	// ProfileRuntimeDefault is the sentinel value that resolves to the
+	// container runtime's own default profile (docker-default for the
+	// docker backend).
+	ProfileRuntimeDefault = "runtime/default"
+	// ProfileNamePrefix must prefix any locally-defined profile name,
+	// e.g. "localhost/my-profile".
+	ProfileNamePrefix = "localhost/"
+	// ProfileNameUnconfined disables AppArmor confinement entirely.
+	ProfileNameUnconfined = "unconfined"
+
+	dockerDefaultProfileName = "docker-default"
+
+	// DefaultAppArmorProfileRoot is where "localhost/<name>" profiles are
+	// read from when no root is explicitly configured, mirroring
+	// seccomp.DefaultSeccompRoot.
+	DefaultAppArmorProfileRoot = "/var/lib/kubelet/apparmor"
+)
+
+// ContainerAnnotationKey returns the pod annotation key that carries the
+// AppArmor profile for the named container.
+func ContainerAnnotationKey(containerName string) string {
+	return ContainerAnnotationKeyPrefix + containerName
+}
+
+// GetProfileName returns the AppArmor profile requested for containerName
+// via annotations, or "" if none was set.
+func GetProfileName(annotations map[string]string, containerName string) string {
+	return annotations[ContainerAnnotationKey(containerName)]
+}
+
+// Validator loads and validates AppArmor profiles named by annotations.
+type Validator struct {
+	// appArmorFS is the path to AppArmor's securityfs mount, used to
+	// check whether a profile is already loaded.
+	appArmorFS string
+	// profileRoot is where "localhost/<name>" profiles are read from and
+	// loaded into the kernel when they aren't already loaded.
+	profileRoot string
+}
+
+// NewValidator returns a Validator that checks profile availability
+// against the given securityfs mount point (typically
+// "/sys/kernel/security/apparmor") and loads "localhost/" profiles from
+// profileRoot. An empty profileRoot defaults to DefaultAppArmorProfileRoot.
+func NewValidator(appArmorFS, profileRoot string) *Validator {
+	if profileRoot == "" {
+		profileRoot = DefaultAppArmorProfileRoot
+	}
+	return &Validator{appArmorFS: appArmorFS, profileRoot: profileRoot}
+}
+
+// ValidateProfile checks that profile can be applied to a container,
+// loading it into the kernel via apparmor_parser first if it is a
+// "localhost/" profile that isn't already loaded. It returns the
+// docker/podman SecurityOpt value to use, e.g. "apparmor=docker-default".
+func (v *Validator) ValidateProfile(profile string) (string, error) {
+	switch {
+	case profile == "" || profile == ProfileRuntimeDefault:
+		return "apparmor=" + dockerDefaultProfileName, nil
+	case profile == ProfileNameUnconfined:
+		return "apparmor=unconfined", nil
+	case strings.HasPrefix(profile, ProfileNamePrefix):
+		name := strings.TrimPrefix(profile, ProfileNamePrefix)
+		if name == "" || strings.Contains(name, "..") {
+			return "", fmt.Errorf("invalid AppArmor profile name %q", profile)
+		}
+		if err := v.ensureLoaded(name); err != nil {
+			return "", err
+		}
+		return "apparmor=" + name, nil
+	default:
+		return "", fmt.Errorf("invalid AppArmor profile name %q: must be unconfined, runtime/default or localhost/<profile-name>", profile)
+	}
+}
+
+// ensureLoaded loads name into the kernel via apparmor_parser if it is not
+// already among the loaded profiles, reading the profile from
+// v.profileRoot/name.
+func (v *Validator) ensureLoaded(name string) error {
+	loaded, err := v.isLoaded(name)
+	if err != nil {
+		return fmt.Errorf("could not check whether AppArmor profile %q is loaded: %v", name, err)
+	}
+	if loaded {
+		return nil
+	}
+	if err := LoadProfile(filepath.Join(v.profileRoot, name)); err != nil {
+		return fmt.Errorf("AppArmor profile %q is not loaded and could not be loaded: %v", name, err)
+	}
+	return nil
+}
+
+// isLoaded reports whether name appears in the kernel's list of loaded
+// AppArmor profiles.
+func (v *Validator) isLoaded(name string) (bool, error) {
+	profiles, err := ioutil.ReadFile(v.appArmorFS + "/profiles")
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(profiles), "\n") {
+		if strings.HasPrefix(line, name+" ") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// LoadProfile loads the AppArmor profile at path into the kernel via
+// apparmor_parser -r -W, replacing any existing profile of the same name.
+// This is used when the profile is shipped as a ConfigMap/inline template
+// materialized to disk by the kubelet rather than pre-installed on the
+// node.
+func LoadProfile(path string) error {
+	out, err := exec.Command("apparmor_parser", "-r", "-W", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to load AppArmor profile %q: %v: %s", path, err, string(out))
+	}
+	glog.V(4).Infof("Loaded AppArmor profile from %s", path)
+	return nil
+}
@@ -0,0 +1,54 @@
+// +build !linux
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apparmor
+
+import "fmt"
+
+const (
+	ContainerAnnotationKeyPrefix = "container.apparmor.security.beta.kubernetes.io/"
+	ProfileRuntimeDefault        = "runtime/default"
+	ProfileNamePrefix            = "localhost/"
+	ProfileNameUnconfined        = "unconfined"
+)
+
+func ContainerAnnotationKey(containerName string) string {
+	return ContainerAnnotationKeyPrefix + containerName
+}
+
+func GetProfileName(annotations map[string]string, containerName string) string {
+	return annotations[ContainerAnnotationKey(containerName)]
+}
+
+// Validator is a no-op on platforms without AppArmor support.
+type Validator struct{}
+
+func NewValidator(appArmorFS, profileRoot string) *Validator {
+	return &Validator{}
+}
+
+func (v *Validator) ValidateProfile(profile string) (string, error) {
+	if profile == "" || profile == ProfileNameUnconfined {
+		return "", nil
+	}
+	return "", fmt.Errorf("AppArmor is not supported on this platform")
+}
+
+func LoadProfile(path string) error {
+	return fmt.Errorf("AppArmor is not supported on this platform")
+}
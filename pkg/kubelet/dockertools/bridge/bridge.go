@@ -0,0 +1,74 @@
+// +build linux
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bridge implements the promiscuous-bridge hairpin strategy: setting
+// the container bridge itself promiscuous so that, combined with the
+// kernel's normal bridge forwarding, a container's traffic to its own
+// Service VIP is NATed back to it without needing per-veth hairpin mode.
+package bridge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// iffPromisc is the IFF_PROMISC bit in an interface's reported flags (see
+// include/uapi/linux/if.h).
+const iffPromisc = 0x100
+
+// Ensurer sets a bridge interface promiscuous if it isn't already.
+type Ensurer struct{}
+
+// NewEnsurer returns an Ensurer.
+func NewEnsurer() *Ensurer {
+	return &Ensurer{}
+}
+
+// EnsurePromiscuous makes sure bridgeName is in promiscuous mode, setting it
+// via `ip link set <bridge> promisc on` if necessary.
+func (e *Ensurer) EnsurePromiscuous(bridgeName string) error {
+	promisc, err := isPromiscuous(bridgeName)
+	if err != nil {
+		return fmt.Errorf("could not determine promiscuous mode of bridge %q: %v", bridgeName, err)
+	}
+	if promisc {
+		return nil
+	}
+	glog.V(3).Infof("Setting bridge %q promiscuous for hairpin NAT", bridgeName)
+	if out, err := exec.Command("ip", "link", "set", bridgeName, "promisc", "on").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set bridge %q promiscuous: %v: %s", bridgeName, err, string(out))
+	}
+	return nil
+}
+
+// isPromiscuous reports whether bridgeName currently has IFF_PROMISC set.
+func isPromiscuous(bridgeName string) (bool, error) {
+	raw, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/flags", bridgeName))
+	if err != nil {
+		return false, err
+	}
+	var flags uint32
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(raw)), "0x%x", &flags); err != nil {
+		return false, fmt.Errorf("unexpected flags format %q: %v", strings.TrimSpace(string(raw)), err)
+	}
+	return flags&iffPromisc != 0, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cgroups computes the cgroup name Docker places a container under,
+// for the two cgroup drivers Docker supports. The kubelet needs this to act
+// on a container's cgroup (e.g. OOM score adjustment) without assuming one
+// particular on-disk layout.
+package cgroups
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Driver resolves the cgroup name of a container started by the Docker daemon.
+type Driver interface {
+	// Name identifies the driver, matching the string Docker's own --exec-opt
+	// native.cgroupdriver (and docker info's CgroupDriver field) uses: "cgroupfs" or "systemd".
+	Name() string
+	// ContainerCgroupName returns the container's cgroup name relative to a cgroup subsystem's
+	// root, given the pod's QoS class, UID, and the container's full Docker ID.
+	ContainerCgroupName(qosClass, podUID, containerID string) string
+}
+
+// New returns the Driver for the named cgroup driver ("cgroupfs" or "systemd"; "" defaults to
+// "cgroupfs"), or an error if name isn't one Docker supports.
+func New(name string) (Driver, error) {
+	switch name {
+	case "", "cgroupfs":
+		return cgroupfsDriver{}, nil
+	case "systemd":
+		return systemdDriver{}, nil
+	default:
+		return nil, fmt.Errorf("unknown cgroup driver %q, must be \"cgroupfs\" or \"systemd\"", name)
+	}
+}
+
+// cgroupfsDriver lays pod cgroups out as plain nested directories:
+// /kubepods/<qos>/pod<uid>/<containerID>
+type cgroupfsDriver struct{}
+
+func (cgroupfsDriver) Name() string { return "cgroupfs" }
+
+func (cgroupfsDriver) ContainerCgroupName(qosClass, podUID, containerID string) string {
+	return path.Join("/kubepods", strings.ToLower(qosClass), "pod"+podUID, containerID)
+}
+
+// systemdDriver mirrors the slice/scope naming Docker's systemd cgroup driver produces:
+// /kubepods-<qos>-pod<uid_with_dashes_replaced_by_underscores>.slice/docker-<containerID>.scope
+type systemdDriver struct{}
+
+func (systemdDriver) Name() string { return "systemd" }
+
+func (systemdDriver) ContainerCgroupName(qosClass, podUID, containerID string) string {
+	slice := fmt.Sprintf("kubepods-%s-pod%s.slice", strings.ToLower(qosClass), strings.Replace(podUID, "-", "_", -1))
+	scope := fmt.Sprintf("docker-%s.scope", containerID)
+	return "/" + slice + "/" + scope
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/kubelet/pod"
+	"k8s.io/kubernetes/pkg/types"
+)
+
+// podCheckpointsDir is the directory, relative to dockerRoot, that holds one checkpoint file
+// per (pod UID, container name), so a container's restart count and last termination state
+// survive both a kubelet restart and the garbage collection of the dead container docker
+// itself would otherwise have derived them from.
+const podCheckpointsDir = "checkpoints"
+
+// podCheckpointRecord is the on-disk representation of what GetPodStatus and doBackOff need
+// about a container once its dead docker instances may no longer be around to inspect.
+type podCheckpointRecord struct {
+	RestartCount         int                `json:"restartCount"`
+	LastTerminationState api.ContainerState `json:"lastTerminationState,omitempty"`
+	FinishedAt           unversioned.Time   `json:"finishedAt,omitempty"`
+	StableName           string             `json:"stableName,omitempty"`
+}
+
+// PodCheckpointManager persists podCheckpointRecords as one JSON file per (pod UID, container
+// name) under dockerRoot/checkpoints/, so restart counts and crash-loop backoff state don't
+// reset to zero just because the dead container instances they were derived from were pruned.
+type PodCheckpointManager struct {
+	lock sync.Mutex
+	dir  string
+}
+
+// NewPodCheckpointManager returns a PodCheckpointManager rooted at dockerRoot/checkpoints,
+// creating the directory if necessary.
+func NewPodCheckpointManager(dockerRoot string) (*PodCheckpointManager, error) {
+	dir := filepath.Join(dockerRoot, podCheckpointsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &PodCheckpointManager{dir: dir}, nil
+}
+
+// checkpointFileName returns the path of the checkpoint file for uid/containerName. '/' can't
+// appear in either component, so joining them with an underscore is unambiguous enough for a
+// node-local file name; uniqueness across all pods only relies on pod UIDs never repeating.
+func (m *PodCheckpointManager) checkpointFileName(uid types.UID, containerName string) string {
+	return filepath.Join(m.dir, fmt.Sprintf("%s_%s.json", uid, containerName))
+}
+
+// Get returns the persisted checkpoint for uid/containerName, if any.
+func (m *PodCheckpointManager) Get(uid types.UID, containerName string) (podCheckpointRecord, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	data, err := ioutil.ReadFile(m.checkpointFileName(uid, containerName))
+	if err != nil {
+		return podCheckpointRecord{}, false
+	}
+	var rec podCheckpointRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		glog.Warningf("PodCheckpointManager: malformed checkpoint for pod %s container %s: %v", uid, containerName, err)
+		return podCheckpointRecord{}, false
+	}
+	return rec, true
+}
+
+// Write atomically persists rec as the checkpoint for uid/containerName, so a kubelet crash
+// mid-write can never leave behind a half-written, unparseable file.
+func (m *PodCheckpointManager) Write(uid types.UID, containerName string, rec podCheckpointRecord) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	path := m.checkpointFileName(uid, containerName)
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Remove deletes the checkpoint for uid/containerName, if one exists.
+func (m *PodCheckpointManager) Remove(uid types.UID, containerName string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := os.Remove(m.checkpointFileName(uid, containerName)); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("PodCheckpointManager: failed to remove checkpoint for pod %s container %s: %v", uid, containerName, err)
+	}
+}
+
+// List returns every checkpoint recorded for uid, keyed by container name. GetPodStatus uses
+// this to fill in status for containers whose last dead docker instance has already been
+// garbage collected and so no longer appears in a live ListContainers result.
+func (m *PodCheckpointManager) List(uid types.UID) map[string]podCheckpointRecord {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entries, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		glog.Errorf("PodCheckpointManager: failed to list checkpoint directory %s: %v", m.dir, err)
+		return nil
+	}
+	recs := make(map[string]podCheckpointRecord)
+	for _, entry := range entries {
+		entryUID, containerName, ok := splitCheckpointFileName(entry.Name())
+		if !ok || entryUID != uid {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(m.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var rec podCheckpointRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			glog.Warningf("PodCheckpointManager: malformed checkpoint for pod %s container %s: %v", uid, containerName, err)
+			continue
+		}
+		recs[containerName] = rec
+	}
+	return recs
+}
+
+// Reconcile prunes checkpoints for pods podManager no longer knows about, so the checkpoint
+// directory doesn't grow unboundedly as pods come and go across the life of the node. It's
+// meant to run once on kubelet startup (after podManager has been populated) and periodically
+// thereafter, mirroring reasonCacheJournal.pruneOrphans.
+func (m *PodCheckpointManager) Reconcile(podManager pod.Manager) {
+	liveUIDs := make(map[types.UID]bool)
+	for _, p := range podManager.GetPods() {
+		liveUIDs[p.UID] = true
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entries, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		glog.Errorf("PodCheckpointManager: failed to list checkpoint directory %s: %v", m.dir, err)
+		return
+	}
+	pruned := 0
+	for _, entry := range entries {
+		uid, _, ok := splitCheckpointFileName(entry.Name())
+		if !ok || liveUIDs[uid] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir, entry.Name())); err != nil {
+			glog.Warningf("PodCheckpointManager: failed to prune stale checkpoint %s: %v", entry.Name(), err)
+			continue
+		}
+		pruned++
+	}
+	if pruned > 0 {
+		glog.V(4).Infof("PodCheckpointManager: pruned %d checkpoints for pods no longer known to the podManager", pruned)
+	}
+}
+
+// splitCheckpointFileName recovers the pod UID and container name encoded by
+// checkpointFileName, or reports ok=false for anything that doesn't look like a checkpoint
+// this manager wrote (e.g. a stray ".tmp" left behind by a crash mid-write).
+func splitCheckpointFileName(name string) (uid types.UID, containerName string, ok bool) {
+	const suffix = ".json"
+	if filepath.Ext(name) != suffix {
+		return "", "", false
+	}
+	base := name[:len(name)-len(suffix)]
+	idx := strings.IndexByte(base, '_')
+	if idx <= 0 || idx == len(base)-1 {
+		return "", "", false
+	}
+	return types.UID(base[:idx]), base[idx+1:], true
+}
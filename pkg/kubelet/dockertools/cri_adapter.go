@@ -0,0 +1,98 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+	"k8s.io/kubernetes/pkg/kubelet/network"
+	"k8s.io/kubernetes/pkg/kubelet/util/format"
+)
+
+// The methods in this file are narrow, CRI-shaped entry points onto DockerManager, exported
+// so a RuntimeService implementation (see pkg/kubelet/dockershim) can drive pod sandbox and
+// container lifecycle one RPC at a time instead of only through the monolithic SyncPod. They
+// don't change SyncPod's own control flow; SyncPod is free to keep doing things inline for
+// now and migrate onto these piecemeal.
+
+// RunPodSandbox creates and starts pod's sandbox: the infra container, its CNI/kubenet
+// network setup, and (for container-network pods) hairpin NAT. It returns the sandbox's
+// Docker ID.
+func (dm *DockerManager) RunPodSandbox(pod *api.Pod) (kubecontainer.DockerID, error) {
+	podInfraContainerID, err, msg := dm.createPodInfraContainer(pod)
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, msg)
+	}
+
+	if !usesHostNetwork(pod) {
+		if err := dm.networkPlugin.SetUpPod(pod.Namespace, pod.Name, podInfraContainerID); err != nil {
+			if delErr := dm.KillContainerInPod(kubecontainer.ContainerID{ID: string(podInfraContainerID), Type: DockerType}, nil, pod, "network setup failed", nil); delErr != nil {
+				glog.Warningf("Failed to clean up sandbox %q for pod %q after a failed network setup: %v", podInfraContainerID, format.Pod(pod), delErr)
+			}
+			return "", fmt.Errorf("failed to set up network for sandbox %q using network plugin %q: %v", podInfraContainerID, dm.networkPlugin.Name(), err)
+		}
+	}
+
+	podInfraContainer, err := dm.client.InspectContainer(string(podInfraContainerID))
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect sandbox %q: %v", podInfraContainerID, err)
+	}
+	// A CNI/kubenet-style plugin already did its own veth discovery and hairpin_mode write as
+	// part of SetUpPod above; dm only still owns hairpin setup for the default (plain Docker
+	// bridge) networking, where there is no plugin to delegate it to.
+	if !usesHostNetwork(pod) && dm.networkPlugin.Name() == network.DefaultPluginName {
+		if err := dm.setupHairpin(podInfraContainer); err != nil {
+			glog.Warningf("Hairpin setup failed for sandbox %q: %v", podInfraContainerID, err)
+		}
+	}
+	return podInfraContainerID, nil
+}
+
+// StopPodSandbox tears down sandboxID's network and stops its infra container.
+func (dm *DockerManager) StopPodSandbox(pod *api.Pod, sandboxID kubecontainer.ContainerID) error {
+	if pod != nil && !usesHostNetwork(pod) {
+		if err := dm.networkPlugin.TearDownPod(pod.Namespace, pod.Name, kubecontainer.DockerID(sandboxID.ID)); err != nil {
+			glog.Errorf("Failed to tear down network for sandbox %q: %v", sandboxID, err)
+		}
+	}
+	return dm.KillContainerInPod(sandboxID, nil, pod, "pod sandbox stopped", nil)
+}
+
+// PodSandboxStatus returns the status of the sandbox identified by sandboxID, including the
+// IP address app containers joining its network namespace will share.
+func (dm *DockerManager) PodSandboxStatus(sandboxID kubecontainer.ContainerID, podName, podNamespace string) (*kubecontainer.ContainerStatus, string, error) {
+	return dm.inspectContainer(sandboxID.ID, podName, podNamespace)
+}
+
+// InspectContainer returns the status of the Docker container identified by id.
+func (dm *DockerManager) InspectContainer(id string, podName, podNamespace string) (*kubecontainer.ContainerStatus, string, error) {
+	return dm.inspectContainer(id, podName, podNamespace)
+}
+
+// StartContainer pulls container's image if needed and starts it joined to sandboxID's
+// network/IPC namespaces, returning the new container's ID.
+func (dm *DockerManager) StartContainer(pod *api.Pod, container *api.Container, sandboxID kubecontainer.DockerID, pullSecrets []api.Secret, restartCount int) (kubecontainer.ContainerID, error) {
+	if err, msg := dm.imagePuller.PullImage(pod, container, pullSecrets); err != nil {
+		return kubecontainer.ContainerID{}, fmt.Errorf("%v: %s", err, msg)
+	}
+	namespaceMode := fmt.Sprintf("container:%v", sandboxID)
+	return dm.runContainerInPod(pod, container, namespaceMode, namespaceMode, getPidMode(pod), restartCount)
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+// dockerRuntimeAdapter is the default ContainerRuntimeAdapter, wrapping a
+// DockerInterface client so DockerManager can drive docker through the same
+// narrow surface used for the podman backend.
+type dockerRuntimeAdapter struct {
+	client DockerInterface
+	puller DockerPuller
+
+	// hostConfigs remembers the docker.HostConfig built by CreateContainer
+	// for each container ID, since docker's StartContainer call (unlike
+	// podman's) needs it again at start time.
+	lock        sync.Mutex
+	hostConfigs map[string]*docker.HostConfig
+}
+
+// NewDockerRuntimeAdapter returns a ContainerRuntimeAdapter that drives
+// containers through the docker daemon over client.
+func NewDockerRuntimeAdapter(client DockerInterface, qps float32, burst int) ContainerRuntimeAdapter {
+	return &dockerRuntimeAdapter{
+		client:      client,
+		puller:      newDockerPuller(client, qps, burst),
+		hostConfigs: make(map[string]*docker.HostConfig),
+	}
+}
+
+func (d *dockerRuntimeAdapter) Name() ContainerRuntimeAdapterName {
+	return RuntimeAdapterDocker
+}
+
+func (d *dockerRuntimeAdapter) CreateContainer(spec *RunContainerSpec) (string, error) {
+	exposedPorts, portBindings := makePortsAndBindings(spec.PortMappings)
+	binds, err := makeMountBindings(spec.Mounts, spec.SELinuxLabel)
+	if err != nil {
+		return "", err
+	}
+
+	opts := docker.CreateContainerOptions{
+		Name: spec.Name,
+		Config: &docker.Config{
+			Env:          spec.Env,
+			ExposedPorts: exposedPorts,
+			Hostname:     spec.Hostname,
+			Image:        spec.Image,
+			Entrypoint:   spec.Entrypoint,
+			Cmd:          spec.Cmd,
+			Memory:       spec.MemoryLimit,
+			MemorySwap:   -1,
+			CPUShares:    spec.CPUShares,
+			WorkingDir:   spec.WorkingDir,
+			Labels:       spec.Labels,
+			OpenStdin:    spec.OpenStdin,
+			StdinOnce:    spec.StdinOnce,
+			Tty:          spec.Tty,
+		},
+	}
+	container, err := d.client.CreateContainer(opts)
+	if err != nil {
+		return "", err
+	}
+
+	d.lock.Lock()
+	d.hostConfigs[container.ID] = &docker.HostConfig{
+		PortBindings: portBindings,
+		Binds:        binds,
+		NetworkMode:  spec.NetworkMode,
+		IpcMode:      spec.IPCMode,
+		UTSMode:      spec.UTSMode,
+		PidMode:      spec.PIDMode,
+		Memory:       spec.MemoryLimit,
+		MemorySwap:   -1,
+		CPUShares:    spec.CPUShares,
+		CPUQuota:     spec.CPUQuota,
+		CPUPeriod:    spec.CPUPeriod,
+		DNS:          spec.DNS,
+		DNSSearch:    spec.DNSSearch,
+		CgroupParent: spec.CgroupParent,
+	}
+	d.lock.Unlock()
+	return container.ID, nil
+}
+
+func (d *dockerRuntimeAdapter) StartContainer(id string) error {
+	d.lock.Lock()
+	hc := d.hostConfigs[id]
+	delete(d.hostConfigs, id)
+	d.lock.Unlock()
+	return d.client.StartContainer(id, hc)
+}
+
+func (d *dockerRuntimeAdapter) StopContainer(id string, gracePeriod uint) error {
+	return d.client.StopContainer(id, gracePeriod)
+}
+
+func (d *dockerRuntimeAdapter) InspectContainer(id string) (*kubecontainer.ContainerStatus, error) {
+	iResult, err := d.client.InspectContainer(id)
+	if err != nil {
+		return nil, err
+	}
+	status := &kubecontainer.ContainerStatus{
+		ID:        kubecontainer.DockerID(id).ContainerID(),
+		Image:     iResult.Config.Image,
+		ImageID:   DockerPrefix + iResult.Image,
+		ExitCode:  iResult.State.ExitCode,
+		CreatedAt: iResult.Created,
+	}
+	if iResult.State.Running {
+		status.State = kubecontainer.ContainerStateRunning
+		status.StartedAt = iResult.State.StartedAt
+		return status, nil
+	}
+	if !iResult.State.FinishedAt.IsZero() || iResult.State.ExitCode != 0 {
+		reason := ""
+		switch {
+		case iResult.State.OOMKilled:
+			reason = "OOMKilled"
+		case iResult.State.ExitCode == 0:
+			reason = "Completed"
+		case !iResult.State.FinishedAt.IsZero():
+			reason = "Error"
+		default:
+			reason = ErrContainerCannotRun.Error()
+		}
+		status.State = kubecontainer.ContainerStateExited
+		status.Reason = reason
+		status.Message = iResult.State.Error
+		status.StartedAt = iResult.State.StartedAt
+		status.FinishedAt = iResult.State.FinishedAt
+	} else {
+		status.State = kubecontainer.ContainerStateUnknown
+	}
+	return status, nil
+}
+
+func (d *dockerRuntimeAdapter) Logs(id string, opts *api.PodLogOptions, stdout, stderr io.Writer) error {
+	var since int64
+	logOpts := docker.LogsOptions{
+		Container:    id,
+		Stdout:       true,
+		Stderr:       true,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+	}
+	if opts != nil {
+		if opts.SinceSeconds != nil {
+			since = unversioned.Now().Add(-time.Duration(*opts.SinceSeconds) * time.Second).Unix()
+		}
+		if opts.SinceTime != nil {
+			since = opts.SinceTime.Unix()
+		}
+		logOpts.Timestamps = opts.Timestamps
+		logOpts.Follow = opts.Follow
+		if opts.TailLines != nil {
+			logOpts.Tail = strconv.FormatInt(*opts.TailLines, 10)
+		}
+	}
+	logOpts.Since = since
+	return d.client.Logs(logOpts)
+}
+
+func (d *dockerRuntimeAdapter) Exec(id string, cmd []string, stdin io.Reader, stdout, stderr io.Writer, tty bool) error {
+	execObj, err := d.client.CreateExec(docker.CreateExecOptions{
+		Container:    id,
+		Cmd:          cmd,
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          tty,
+	})
+	if err != nil {
+		return err
+	}
+	return d.client.StartExec(execObj.ID, docker.StartExecOptions{
+		InputStream:  stdin,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+		RawTerminal:  tty,
+	})
+}
+
+func (d *dockerRuntimeAdapter) PullImage(image string, pullSecrets []api.Secret) error {
+	return d.puller.Pull(image, pullSecrets)
+}
+
+func (d *dockerRuntimeAdapter) ContainerPID(id string) (int, error) {
+	c, err := d.client.InspectContainer(id)
+	if err != nil {
+		return 0, err
+	}
+	return c.State.Pid, nil
+}
+
+var _ ContainerRuntimeAdapter = &dockerRuntimeAdapter{}
@@ -0,0 +1,179 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"sync"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+	proberesults "k8s.io/kubernetes/pkg/kubelet/prober/results"
+)
+
+// dockerHealthCheckPollInterval bounds how often DockerHealthCheckManager re-inspects a
+// container for its Docker-reported health. Docker itself runs the actual HEALTHCHECK on
+// the image's (or the overridden, see dockerHealthConfigFromProbe) interval; this only bounds
+// how quickly a status change it already made is noticed and republished.
+const dockerHealthCheckPollInterval = 2 * time.Second
+
+// DockerHealthCheckManager polls the health status Docker computes from a container
+// image's own HEALTHCHECK instruction (InspectContainer().State.Health) and publishes it
+// into livenessManager, so a pod's livenessProbe can be satisfied by Docker's own check
+// (via a Handler.DockerHealthCheck probe) instead of the kubelet executing one itself.
+type DockerHealthCheckManager struct {
+	dm *DockerManager
+
+	lock   sync.Mutex
+	stopCh map[string]chan struct{} // keyed by container ID
+}
+
+// NewDockerHealthCheckManager returns a DockerHealthCheckManager that inspects containers
+// and records events through dm.
+func NewDockerHealthCheckManager(dm *DockerManager) *DockerHealthCheckManager {
+	return &DockerHealthCheckManager{
+		dm:     dm,
+		stopCh: make(map[string]chan struct{}),
+	}
+}
+
+// Start begins polling containerID's Docker health status on behalf of pod/container, if
+// container's livenessProbe is a Handler.DockerHealthCheck probe. It's a no-op for any
+// other probe type (including no probe at all), and idempotent for a container already
+// being polled.
+func (m *DockerHealthCheckManager) Start(pod *api.Pod, container *api.Container, containerID kubecontainer.ContainerID) {
+	probe := container.LivenessProbe
+	if probe == nil || probe.Handler.DockerHealthCheck == nil {
+		return
+	}
+
+	m.lock.Lock()
+	if _, exists := m.stopCh[containerID.ID]; exists {
+		m.lock.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.stopCh[containerID.ID] = stop
+	m.lock.Unlock()
+
+	go m.run(pod, containerID, stop)
+}
+
+// Stop cancels polling for containerID, if any is in progress.
+func (m *DockerHealthCheckManager) Stop(containerID kubecontainer.ContainerID) {
+	m.lock.Lock()
+	stop, exists := m.stopCh[containerID.ID]
+	if exists {
+		delete(m.stopCh, containerID.ID)
+	}
+	m.lock.Unlock()
+	if exists {
+		close(stop)
+	}
+}
+
+func (m *DockerHealthCheckManager) run(pod *api.Pod, containerID kubecontainer.ContainerID, stop chan struct{}) {
+	ticker := time.NewTicker(dockerHealthCheckPollInterval)
+	defer ticker.Stop()
+
+	var previous proberesults.Result
+	havePrevious := false
+	for {
+		select {
+		case <-ticker.C:
+			m.poll(pod, containerID, &previous, &havePrevious)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (m *DockerHealthCheckManager) poll(pod *api.Pod, containerID kubecontainer.ContainerID, previous *proberesults.Result, havePrevious *bool) {
+	inspect, err := m.dm.client.InspectContainer(containerID.ID)
+	if err != nil {
+		glog.V(4).Infof("Unable to inspect container %s for Docker health status: %v", containerID.ID, err)
+		return
+	}
+	if inspect.State.Health == nil {
+		// The image doesn't declare a HEALTHCHECK, or Docker hasn't run one yet.
+		return
+	}
+
+	result, ok := dockerHealthToResult(inspect.State.Health.Status)
+	if !ok {
+		return
+	}
+
+	m.dm.livenessManager.Set(containerID, result, pod)
+
+	if *havePrevious && *previous == result && result == proberesults.Success {
+		return
+	}
+	if result == proberesults.Failure && (!*havePrevious || *previous != proberesults.Failure) {
+		output := ""
+		if logs := inspect.State.Health.Log; len(logs) > 0 {
+			output = logs[len(logs)-1].Output
+		}
+		if ref, ok := m.dm.containerRefManager.GetRef(containerID); ok {
+			m.dm.recorder.Eventf(ref, api.EventTypeWarning, "Unhealthy", "Docker health check failed: %s", output)
+		}
+	}
+	*previous = result
+	*havePrevious = true
+}
+
+// dockerHealthToResult maps a Docker health status string (Docker's State.Health.Status)
+// to a proberesults.Result. It returns ok=false for "starting", which isn't a verdict yet.
+func dockerHealthToResult(status string) (result proberesults.Result, ok bool) {
+	switch status {
+	case "healthy":
+		return proberesults.Success, true
+	case "unhealthy":
+		return proberesults.Failure, true
+	default:
+		return proberesults.Result(false), false
+	}
+}
+
+// dockerHealthConfigFromProbe builds the docker.HealthConfig overrides a pod's
+// Handler.DockerHealthCheck livenessProbe requests, so operators can tune an image's
+// HEALTHCHECK cadence per pod without rebuilding the image. The Test command itself is
+// left untouched (empty), which go-dockerclient omits, so Docker continues to run the
+// image's own HEALTHCHECK CMD.
+func dockerHealthConfigFromProbe(probe *api.Probe) *docker.HealthConfig {
+	if probe == nil || probe.Handler.DockerHealthCheck == nil {
+		return nil
+	}
+	hc := &docker.HealthConfig{}
+	if probe.PeriodSeconds > 0 {
+		hc.Interval = time.Duration(probe.PeriodSeconds) * time.Second
+	}
+	if probe.TimeoutSeconds > 0 {
+		hc.Timeout = time.Duration(probe.TimeoutSeconds) * time.Second
+	}
+	if probe.FailureThreshold > 0 {
+		hc.Retries = int(probe.FailureThreshold)
+	}
+	if hc.Interval == 0 && hc.Timeout == 0 && hc.Retries == 0 {
+		return nil
+	}
+	return hc
+}
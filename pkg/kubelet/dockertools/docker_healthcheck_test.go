@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+	proberesults "k8s.io/kubernetes/pkg/kubelet/prober/results"
+)
+
+func TestDockerHealthToResult(t *testing.T) {
+	cases := []struct {
+		status string
+		want   proberesults.Result
+		wantOk bool
+	}{
+		{"healthy", proberesults.Success, true},
+		{"unhealthy", proberesults.Failure, true},
+		{"starting", proberesults.Result(false), false},
+		{"", proberesults.Result(false), false},
+	}
+	for _, c := range cases {
+		got, ok := dockerHealthToResult(c.status)
+		if ok != c.wantOk {
+			t.Errorf("dockerHealthToResult(%q) ok = %v, want %v", c.status, ok, c.wantOk)
+			continue
+		}
+		if ok && got != c.want {
+			t.Errorf("dockerHealthToResult(%q) = %v, want %v", c.status, got, c.want)
+		}
+	}
+}
+
+func TestDockerHealthConfigFromProbeNoDockerHealthCheck(t *testing.T) {
+	if hc := dockerHealthConfigFromProbe(nil); hc != nil {
+		t.Errorf("expected nil HealthConfig for a nil probe, got %+v", hc)
+	}
+	probe := &api.Probe{Handler: api.Handler{Exec: &api.ExecAction{Command: []string{"true"}}}}
+	if hc := dockerHealthConfigFromProbe(probe); hc != nil {
+		t.Errorf("expected nil HealthConfig for a non-DockerHealthCheck probe, got %+v", hc)
+	}
+}
+
+func TestDockerHealthConfigFromProbeOverrides(t *testing.T) {
+	probe := &api.Probe{
+		Handler:          api.Handler{DockerHealthCheck: &api.DockerHealthCheckAction{}},
+		PeriodSeconds:    5,
+		TimeoutSeconds:   2,
+		FailureThreshold: 4,
+	}
+	hc := dockerHealthConfigFromProbe(probe)
+	if hc == nil {
+		t.Fatal("expected a non-nil HealthConfig for a DockerHealthCheck probe")
+	}
+	if hc.Interval != 5*time.Second || hc.Timeout != 2*time.Second || hc.Retries != 4 {
+		t.Errorf("got %+v, want Interval=5s Timeout=2s Retries=4", hc)
+	}
+}
+
+func TestDockerHealthCheckManagerStartStopIsIdempotent(t *testing.T) {
+	m := NewDockerHealthCheckManager(nil)
+	containerID := kubecontainer.ContainerID{Type: "docker", ID: "container-1"}
+
+	// No DockerHealthCheck probe: Start is a no-op.
+	m.Start(&api.Pod{}, &api.Container{}, containerID)
+	if len(m.stopCh) != 0 {
+		t.Errorf("expected no polling goroutine for a container without a DockerHealthCheck probe")
+	}
+
+	container := &api.Container{
+		LivenessProbe: &api.Probe{Handler: api.Handler{DockerHealthCheck: &api.DockerHealthCheckAction{}}},
+	}
+	m.Start(&api.Pod{}, container, containerID)
+	m.Start(&api.Pod{}, container, containerID) // second Start() for the same container is a no-op
+
+	m.Stop(containerID)
+	m.Stop(containerID) // second Stop() for an already-stopped container is a no-op
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+// ExecSessionState tracks the lifecycle of a session managed by
+// ExecSessionManager.
+type ExecSessionState string
+
+const (
+	// ExecSessionRunning is set once StartExec has been issued and the
+	// background inspect goroutine hasn't yet observed completion.
+	ExecSessionRunning ExecSessionState = "Running"
+	// ExecSessionStopped is set once the exec process has exited.
+	ExecSessionStopped ExecSessionState = "Stopped"
+	// ExecSessionRemoved is set once a caller has pruned the session from
+	// the registry; sessions in this state are no longer returned by
+	// Inspect.
+	ExecSessionRemoved ExecSessionState = "Removed"
+)
+
+// execSession is the bookkeeping ExecSessionManager keeps per live or
+// recently-completed docker exec, modeled on podman's libpod exec-session
+// registry so that attach/detach/resize don't need to create a fresh
+// docker exec for every client reconnect.
+type execSession struct {
+	ContainerID kubecontainer.ContainerID
+	State       ExecSessionState
+	ExitCode    int
+
+	// done is closed by the background inspect goroutine once the exec
+	// process exits, letting callers block on completion without
+	// polling.
+	done chan struct{}
+}
+
+// execSessionClient is the slice of DockerInterface ExecSessionManager
+// actually needs, kept narrow (in the spirit of dockerPuller/ExecHandler)
+// so it can be exercised with a small fake in tests instead of a full
+// DockerInterface stub.
+type execSessionClient interface {
+	CreateExec(docker.CreateExecOptions) (*docker.Exec, error)
+	StartExec(string, docker.StartExecOptions) error
+	InspectExec(string) (*docker.ExecInspect, error)
+	ResizeExecTTY(id string, height, width int) error
+}
+
+// ExecSessionManager allocates, tracks and lets callers re-attach to
+// docker exec sessions. A single session can be resized and detached
+// from/re-attached to any number of times before it completes.
+type ExecSessionManager struct {
+	client execSessionClient
+
+	lock     sync.Mutex
+	sessions map[string]*execSession
+}
+
+// NewExecSessionManager returns an ExecSessionManager backed by client.
+func NewExecSessionManager(client execSessionClient) *ExecSessionManager {
+	return &ExecSessionManager{
+		client:   client,
+		sessions: make(map[string]*execSession),
+	}
+}
+
+// Create allocates a new exec session in containerID running cmd, starts
+// it attached to stdin/stdout/stderr, and returns the session ID callers
+// should pass to Attach/Detach/Resize/Inspect. The session ID is the
+// underlying docker exec ID, since that's already unique per exec and
+// there's no need to mint a second identifier for it.
+func (m *ExecSessionManager) Create(containerID kubecontainer.ContainerID, cmd []string, stdin io.Reader, stdout, stderr io.Writer, tty bool) (string, error) {
+	execObj, err := m.client.CreateExec(docker.CreateExecOptions{
+		Container:    containerID.ID,
+		Cmd:          cmd,
+		AttachStdin:  stdin != nil,
+		AttachStdout: stdout != nil,
+		AttachStderr: stderr != nil,
+		Tty:          tty,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create exec session: %v", err)
+	}
+
+	session := &execSession{
+		ContainerID: containerID,
+		State:       ExecSessionRunning,
+		done:        make(chan struct{}),
+	}
+	m.lock.Lock()
+	m.sessions[execObj.ID] = session
+	m.lock.Unlock()
+
+	if err := m.client.StartExec(execObj.ID, docker.StartExecOptions{
+		Detach:       false,
+		Tty:          tty,
+		RawTerminal:  tty,
+		InputStream:  stdin,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+	}); err != nil {
+		m.lock.Lock()
+		session.State = ExecSessionStopped
+		close(session.done)
+		m.lock.Unlock()
+		return "", fmt.Errorf("failed to start exec session: %v", err)
+	}
+
+	go m.watch(execObj.ID, session)
+
+	return execObj.ID, nil
+}
+
+// watch polls InspectExec until the session exits, then records its exit
+// code and closes session.done so Wait can unblock callers without
+// polling themselves.
+func (m *ExecSessionManager) watch(sessionID string, session *execSession) {
+	for {
+		inspect, err := m.client.InspectExec(sessionID)
+		if err != nil || !inspect.Running {
+			m.lock.Lock()
+			session.State = ExecSessionStopped
+			if inspect != nil {
+				session.ExitCode = inspect.ExitCode
+			}
+			close(session.done)
+			m.lock.Unlock()
+			return
+		}
+		<-time.After(2 * time.Second)
+	}
+}
+
+// Wait blocks until sessionID completes and returns its exit code.
+func (m *ExecSessionManager) Wait(sessionID string) (int, error) {
+	session, err := m.get(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	<-session.done
+	return session.ExitCode, nil
+}
+
+// Attach re-attaches to a live sessionID, streaming stdin/stdout/stderr
+// until the session completes or the caller's streams are closed. It can
+// be called again after Detach to reconnect to the same exec.
+func (m *ExecSessionManager) Attach(sessionID string, stdin io.Reader, stdout, stderr io.Writer, tty bool) error {
+	if _, err := m.get(sessionID); err != nil {
+		return err
+	}
+	return m.client.StartExec(sessionID, docker.StartExecOptions{
+		Detach:       false,
+		Tty:          tty,
+		RawTerminal:  tty,
+		InputStream:  stdin,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+	})
+}
+
+// Detach is a no-op beyond validating the session still exists: the
+// underlying docker exec keeps running once started, so "detaching" is
+// simply the caller walking away from its streams and later calling
+// Attach again to reconnect.
+func (m *ExecSessionManager) Detach(sessionID string) error {
+	_, err := m.get(sessionID)
+	return err
+}
+
+// Resize propagates a TTY resize to a live session.
+func (m *ExecSessionManager) Resize(sessionID string, height, width int) error {
+	if _, err := m.get(sessionID); err != nil {
+		return err
+	}
+	return m.client.ResizeExecTTY(sessionID, height, width)
+}
+
+// Inspect returns the current state of sessionID.
+func (m *ExecSessionManager) Inspect(sessionID string) (ExecSessionState, int, error) {
+	session, err := m.get(sessionID)
+	if err != nil {
+		return "", 0, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return session.State, session.ExitCode, nil
+}
+
+// Prune removes every completed (Stopped) session from the registry,
+// freeing their bookkeeping once callers are done inspecting exit codes.
+func (m *ExecSessionManager) Prune() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for id, session := range m.sessions {
+		if session.State == ExecSessionStopped {
+			session.State = ExecSessionRemoved
+			delete(m.sessions, id)
+		}
+	}
+}
+
+func (m *ExecSessionManager) get(sessionID string) (*execSession, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("no such exec session %q", sessionID)
+	}
+	return session, nil
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+// fakeExecClient is a minimal execSessionClient that finishes every exec
+// immediately (InspectExec always reports Running as false) and records
+// resize calls.
+type fakeExecClient struct {
+	lock      sync.Mutex
+	nextID    int32
+	inspected map[string]bool // whether InspectExec has already been polled once
+	resizes   map[string][2]int
+}
+
+func newFakeExecClient() *fakeExecClient {
+	return &fakeExecClient{
+		inspected: make(map[string]bool),
+		resizes:   make(map[string][2]int),
+	}
+}
+
+func (f *fakeExecClient) CreateExec(docker.CreateExecOptions) (*docker.Exec, error) {
+	id := atomic.AddInt32(&f.nextID, 1)
+	return &docker.Exec{ID: "exec-" + strconv.Itoa(int(id))}, nil
+}
+
+func (f *fakeExecClient) StartExec(id string, opts docker.StartExecOptions) error {
+	if opts.OutputStream != nil {
+		opts.OutputStream.Write([]byte("ok"))
+	}
+	return nil
+}
+
+// InspectExec always reports the exec as already finished, so
+// ExecSessionManager's background watch loop resolves on its very first
+// poll instead of sleeping through a real 2-second tick in tests.
+func (f *fakeExecClient) InspectExec(id string) (*docker.ExecInspect, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.inspected[id] = true
+	return &docker.ExecInspect{ID: id, Running: false}, nil
+}
+
+func (f *fakeExecClient) ResizeExecTTY(id string, height, width int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.resizes[id] = [2]int{height, width}
+	return nil
+}
+
+func TestExecSessionManagerConcurrentAttach(t *testing.T) {
+	m := NewExecSessionManager(newFakeExecClient())
+	containerID := kubecontainer.ContainerID{Type: "docker", ID: "container-1"}
+
+	const n = 10
+	ids := make([]string, n)
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := m.Create(containerID, []string{"true"}, nil, nil, nil, false)
+			ids[i] = id
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Create() %d returned error: %v", i, err)
+		}
+		if seen[ids[i]] {
+			t.Fatalf("session ID %q was allocated twice", ids[i])
+		}
+		seen[ids[i]] = true
+		if _, _, err := m.Inspect(ids[i]); err != nil {
+			t.Errorf("Inspect(%q) returned error: %v", ids[i], err)
+		}
+	}
+
+	// Attach to every session concurrently.
+	attachErrs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			attachErrs[i] = m.Attach(ids[i], nil, nil, nil, false)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range attachErrs {
+		if err != nil {
+			t.Errorf("Attach(%q) returned error: %v", ids[i], err)
+		}
+	}
+}
+
+func TestExecSessionManagerDetachThenReattach(t *testing.T) {
+	m := NewExecSessionManager(newFakeExecClient())
+	containerID := kubecontainer.ContainerID{Type: "docker", ID: "container-1"}
+
+	sessionID, err := m.Create(containerID, []string{"sh"}, nil, nil, nil, true)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	if err := m.Detach(sessionID); err != nil {
+		t.Fatalf("Detach() returned error: %v", err)
+	}
+
+	if err := m.Attach(sessionID, nil, nil, nil, true); err != nil {
+		t.Fatalf("Attach() after Detach() returned error: %v", err)
+	}
+
+	if err := m.Detach("no-such-session"); err == nil {
+		t.Error("expected Detach() of an unknown session to return an error")
+	}
+}
+
+func TestExecSessionManagerResizePropagation(t *testing.T) {
+	client := newFakeExecClient()
+	m := NewExecSessionManager(client)
+	containerID := kubecontainer.ContainerID{Type: "docker", ID: "container-1"}
+
+	sessionID, err := m.Create(containerID, []string{"sh"}, nil, nil, nil, true)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	if err := m.Resize(sessionID, 24, 80); err != nil {
+		t.Fatalf("Resize() returned error: %v", err)
+	}
+
+	client.lock.Lock()
+	got, ok := client.resizes[sessionID]
+	client.lock.Unlock()
+	if !ok {
+		t.Fatalf("expected ResizeExecTTY to be called for session %q", sessionID)
+	}
+	if got != [2]int{24, 80} {
+		t.Errorf("ResizeExecTTY called with %v, want [24 80]", got)
+	}
+
+	if err := m.Resize("no-such-session", 1, 1); err == nil {
+		t.Error("expected Resize() of an unknown session to return an error")
+	}
+}
+
+func TestExecSessionManagerWaitAndPrune(t *testing.T) {
+	m := NewExecSessionManager(newFakeExecClient())
+	containerID := kubecontainer.ContainerID{Type: "docker", ID: "container-1"}
+
+	sessionID, err := m.Create(containerID, []string{"true"}, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	if _, err := m.Wait(sessionID); err != nil {
+		t.Fatalf("Wait() returned error: %v", err)
+	}
+
+	state, _, err := m.Inspect(sessionID)
+	if err != nil {
+		t.Fatalf("Inspect() returned error: %v", err)
+	}
+	if state != ExecSessionStopped {
+		t.Errorf("state = %v, want %v", state, ExecSessionStopped)
+	}
+
+	m.Prune()
+	if _, _, err := m.Inspect(sessionID); err == nil {
+		t.Error("expected Inspect() of a pruned session to return an error")
+	}
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+// healthCheckLabel stores the JSON-encoded healthCheckSpec for a
+// container, written at create time in runContainer, so a restarted
+// kubelet can rehydrate the running schedule via RehydrateHealthChecks
+// instead of losing track of it.
+const healthCheckLabel = "io.kubernetes.container.healthcheck"
+
+// HealthStatus is the rolling result of a single native health check
+// execution, analogous to podman's healthy/unhealthy/starting states.
+type HealthStatus string
+
+const (
+	HealthStatusStarting  HealthStatus = "starting"
+	HealthStatusHealthy   HealthStatus = "healthy"
+	HealthStatusUnhealthy HealthStatus = "unhealthy"
+)
+
+const (
+	// healthLogSize caps how many recent results GetHealthLog retains.
+	healthLogSize = 5
+
+	// Defaults mirror Docker's own HEALTHCHECK defaults so a probe that
+	// doesn't customize interval/timeout/retries/start-period still gets
+	// a reasonable native schedule.
+	defaultHealthCheckInterval    = 30 * time.Second
+	defaultHealthCheckTimeout     = 30 * time.Second
+	defaultHealthCheckRetries     = 3
+	defaultHealthCheckStartPeriod = 0 * time.Second
+)
+
+// HealthCheckResult is one entry in a container's rolling health log.
+type HealthCheckResult struct {
+	Status HealthStatus
+	Time   time.Time
+	Output string
+}
+
+// healthCheckSpec is the native health check schedule HealthCheckRunner
+// executes for a container, run independently of the container engine so
+// that image-level HEALTHCHECK directives are irrelevant and the probe
+// definition lives entirely with the pod.
+type healthCheckSpec struct {
+	Kind string `json:"kind"` // "exec" or "httpGet"
+
+	Cmd  []string `json:"cmd,omitempty"`
+	Path string   `json:"path,omitempty"`
+	Port int      `json:"port,omitempty"`
+
+	IntervalSeconds    int `json:"intervalSeconds"`
+	TimeoutSeconds     int `json:"timeoutSeconds"`
+	Retries            int `json:"retries"`
+	StartPeriodSeconds int `json:"startPeriodSeconds"`
+}
+
+func (s *healthCheckSpec) interval() time.Duration {
+	return time.Duration(s.IntervalSeconds) * time.Second
+}
+
+func (s *healthCheckSpec) timeout() time.Duration {
+	return time.Duration(s.TimeoutSeconds) * time.Second
+}
+
+func (s *healthCheckSpec) startPeriod() time.Duration {
+	return time.Duration(s.StartPeriodSeconds) * time.Second
+}
+
+// healthCheckSpecFromContainer derives a native health check schedule
+// from container's livenessProbe, if it has one HealthCheckRunner can act
+// on (Exec or HTTPGet). Returns nil if there's nothing to run natively.
+func healthCheckSpecFromContainer(container *api.Container) *healthCheckSpec {
+	probe := container.LivenessProbe
+	if probe == nil {
+		return nil
+	}
+
+	spec := &healthCheckSpec{
+		IntervalSeconds:    int(defaultHealthCheckInterval.Seconds()),
+		TimeoutSeconds:     int(defaultHealthCheckTimeout.Seconds()),
+		Retries:            defaultHealthCheckRetries,
+		StartPeriodSeconds: int(defaultHealthCheckStartPeriod.Seconds()),
+	}
+	switch {
+	case probe.Exec != nil:
+		spec.Kind = "exec"
+		spec.Cmd = probe.Exec.Command
+	case probe.HTTPGet != nil:
+		spec.Kind = "httpGet"
+		spec.Path = probe.HTTPGet.Path
+		spec.Port = probe.HTTPGet.Port.IntValue()
+	default:
+		return nil
+	}
+
+	if probe.PeriodSeconds > 0 {
+		spec.IntervalSeconds = int(probe.PeriodSeconds)
+	}
+	if probe.TimeoutSeconds > 0 {
+		spec.TimeoutSeconds = int(probe.TimeoutSeconds)
+	}
+	if probe.FailureThreshold > 0 {
+		spec.Retries = int(probe.FailureThreshold)
+	}
+	if probe.InitialDelaySeconds > 0 {
+		spec.StartPeriodSeconds = int(probe.InitialDelaySeconds)
+	}
+	return spec
+}
+
+// containerHealth is the live state HealthCheckRunner tracks for a single
+// container under native health checking.
+type containerHealth struct {
+	lock     sync.Mutex
+	log      []HealthCheckResult
+	failures int
+	status   HealthStatus
+
+	stop chan struct{}
+}
+
+func (h *containerHealth) record(result HealthCheckResult) HealthStatus {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	previous := h.status
+	h.log = append(h.log, result)
+	if len(h.log) > healthLogSize {
+		h.log = h.log[len(h.log)-healthLogSize:]
+	}
+	h.status = result.Status
+	return previous
+}
+
+func (h *containerHealth) snapshot() []HealthCheckResult {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	out := make([]HealthCheckResult, len(h.log))
+	copy(out, h.log)
+	return out
+}
+
+// HealthCheckRunner runs container livenessProbes natively, via the
+// exec-session path (see exec_session.go) or a direct HTTP GET, instead
+// of relying on an image's own Docker HEALTHCHECK directive.
+type HealthCheckRunner struct {
+	dm *DockerManager
+
+	lock       sync.Mutex
+	containers map[string]*containerHealth // keyed by container ID
+}
+
+// NewHealthCheckRunner returns a HealthCheckRunner that schedules checks
+// through dm.
+func NewHealthCheckRunner(dm *DockerManager) *HealthCheckRunner {
+	return &HealthCheckRunner{
+		dm:         dm,
+		containers: make(map[string]*containerHealth),
+	}
+}
+
+// Start schedules periodic native health checks for containerID according
+// to spec. It's a no-op if a schedule is already running for containerID.
+func (r *HealthCheckRunner) Start(containerID kubecontainer.ContainerID, spec *healthCheckSpec) {
+	if spec == nil {
+		return
+	}
+	r.lock.Lock()
+	if _, exists := r.containers[containerID.ID]; exists {
+		r.lock.Unlock()
+		return
+	}
+	health := &containerHealth{status: HealthStatusStarting, stop: make(chan struct{})}
+	r.containers[containerID.ID] = health
+	r.lock.Unlock()
+
+	go r.run(containerID, spec, health)
+}
+
+// Stop cancels the native health check schedule running for containerID,
+// if any.
+func (r *HealthCheckRunner) Stop(containerID kubecontainer.ContainerID) {
+	r.lock.Lock()
+	health, exists := r.containers[containerID.ID]
+	if exists {
+		delete(r.containers, containerID.ID)
+	}
+	r.lock.Unlock()
+	if exists {
+		close(health.stop)
+	}
+}
+
+// GetHealthLog returns the rolling native health check results recorded
+// for containerID, oldest first.
+func (r *HealthCheckRunner) GetHealthLog(containerID kubecontainer.ContainerID) []HealthCheckResult {
+	r.lock.Lock()
+	health, exists := r.containers[containerID.ID]
+	r.lock.Unlock()
+	if !exists {
+		return nil
+	}
+	return health.snapshot()
+}
+
+// RehydrateHealthChecks restarts native health check schedules for every
+// running container carrying a healthCheckLabel, so a kubelet restart
+// doesn't silently stop checking containers it didn't create this time
+// around.
+func (r *HealthCheckRunner) RehydrateHealthChecks() error {
+	containers, err := GetKubeletDockerContainers(r.dm.client, false)
+	if err != nil {
+		return fmt.Errorf("failed to list containers while rehydrating health checks: %v", err)
+	}
+	for _, c := range containers {
+		data, ok := c.Labels[healthCheckLabel]
+		if !ok {
+			continue
+		}
+		spec := &healthCheckSpec{}
+		if err := json.Unmarshal([]byte(data), spec); err != nil {
+			glog.Errorf("Failed to decode health check schedule for container %s: %v", c.ID, err)
+			continue
+		}
+		containerID := kubecontainer.ContainerID{Type: DockerType, ID: c.ID}
+		r.Start(containerID, spec)
+	}
+	return nil
+}
+
+func (r *HealthCheckRunner) run(containerID kubecontainer.ContainerID, spec *healthCheckSpec, health *containerHealth) {
+	select {
+	case <-time.After(spec.startPeriod()):
+	case <-health.stop:
+		return
+	}
+
+	ticker := time.NewTicker(spec.interval())
+	defer ticker.Stop()
+	for {
+		r.check(containerID, spec, health)
+		select {
+		case <-ticker.C:
+		case <-health.stop:
+			return
+		}
+	}
+}
+
+func (r *HealthCheckRunner) check(containerID kubecontainer.ContainerID, spec *healthCheckSpec, health *containerHealth) {
+	status, output, err := r.probeOnce(containerID, spec)
+	if err != nil {
+		output = err.Error()
+	}
+	previous := health.record(HealthCheckResult{Status: status, Time: time.Now(), Output: output})
+	if previous != status {
+		if ref, ok := r.dm.containerRefManager.GetRef(containerID); ok {
+			r.dm.recorder.Eventf(ref, api.EventTypeNormal, "HealthStatusChanged", "Health status changed from %s to %s", previous, status)
+		}
+	}
+
+	if status == HealthStatusHealthy {
+		health.lock.Lock()
+		health.failures = 0
+		health.lock.Unlock()
+		return
+	}
+
+	health.lock.Lock()
+	health.failures++
+	failures := health.failures
+	health.lock.Unlock()
+
+	if failures >= spec.Retries {
+		glog.Errorf("Container %s failed %d consecutive health checks, killing it", containerID.ID, failures)
+		if err := r.dm.KillContainerInPod(containerID, nil, nil, "health check failed", nil); err != nil {
+			glog.Errorf("Failed to kill unhealthy container %s: %v", containerID.ID, err)
+		}
+	}
+}
+
+func (r *HealthCheckRunner) probeOnce(containerID kubecontainer.ContainerID, spec *healthCheckSpec) (HealthStatus, string, error) {
+	if spec.Kind == "exec" {
+		return r.probeExec(containerID, spec)
+	}
+	return r.probeHTTPGet(containerID, spec)
+}
+
+func (r *HealthCheckRunner) probeExec(containerID kubecontainer.ContainerID, spec *healthCheckSpec) (HealthStatus, string, error) {
+	var buf bytes.Buffer
+	sessionID, err := r.dm.execSessions.Create(containerID, spec.Cmd, nil, &buf, &buf, false)
+	if err != nil {
+		return HealthStatusUnhealthy, "", err
+	}
+	defer r.dm.execSessions.Prune()
+
+	type waitResult struct {
+		code int
+		err  error
+	}
+	resultCh := make(chan waitResult, 1)
+	go func() {
+		code, err := r.dm.execSessions.Wait(sessionID)
+		resultCh <- waitResult{code, err}
+	}()
+
+	select {
+	case res := <-resultCh:
+		if res.err != nil {
+			return HealthStatusUnhealthy, buf.String(), res.err
+		}
+		if res.code != 0 {
+			return HealthStatusUnhealthy, buf.String(), fmt.Errorf("health check command exited with code %d", res.code)
+		}
+		return HealthStatusHealthy, buf.String(), nil
+	case <-time.After(spec.timeout()):
+		return HealthStatusUnhealthy, buf.String(), fmt.Errorf("health check timed out after %s", spec.timeout())
+	}
+}
+
+func (r *HealthCheckRunner) probeHTTPGet(containerID kubecontainer.ContainerID, spec *healthCheckSpec) (HealthStatus, string, error) {
+	ip, err := r.dm.GetContainerIP(containerID.ID, "eth0")
+	if err != nil {
+		return HealthStatusUnhealthy, "", err
+	}
+	url := fmt.Sprintf("http://%s:%d%s", ip, spec.Port, spec.Path)
+	client := &http.Client{Timeout: spec.timeout()}
+	resp, err := client.Get(url)
+	if err != nil {
+		return HealthStatusUnhealthy, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return HealthStatusUnhealthy, "", fmt.Errorf("health check GET %s returned status %d", url, resp.StatusCode)
+	}
+	return HealthStatusHealthy, fmt.Sprintf("HTTP %d", resp.StatusCode), nil
+}
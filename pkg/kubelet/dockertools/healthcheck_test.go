@@ -0,0 +1,130 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+func TestHealthCheckSpecFromContainerNoProbe(t *testing.T) {
+	if spec := healthCheckSpecFromContainer(&api.Container{}); spec != nil {
+		t.Errorf("expected nil spec for a container without a livenessProbe, got %+v", spec)
+	}
+}
+
+func TestHealthCheckSpecFromContainerExecDefaults(t *testing.T) {
+	container := &api.Container{
+		LivenessProbe: &api.Probe{
+			Handler: api.Handler{
+				Exec: &api.ExecAction{Command: []string{"cat", "/healthy"}},
+			},
+		},
+	}
+	spec := healthCheckSpecFromContainer(container)
+	if spec == nil {
+		t.Fatal("expected a non-nil spec for a container with an Exec livenessProbe")
+	}
+	if spec.Kind != "exec" {
+		t.Errorf("Kind = %q, want %q", spec.Kind, "exec")
+	}
+	if spec.IntervalSeconds != int(defaultHealthCheckInterval.Seconds()) {
+		t.Errorf("IntervalSeconds = %d, want default %d", spec.IntervalSeconds, int(defaultHealthCheckInterval.Seconds()))
+	}
+	if spec.Retries != defaultHealthCheckRetries {
+		t.Errorf("Retries = %d, want default %d", spec.Retries, defaultHealthCheckRetries)
+	}
+}
+
+func TestHealthCheckSpecFromContainerHTTPGetOverrides(t *testing.T) {
+	container := &api.Container{
+		LivenessProbe: &api.Probe{
+			Handler: api.Handler{
+				HTTPGet: &api.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(8080)},
+			},
+			PeriodSeconds:       10,
+			TimeoutSeconds:      5,
+			FailureThreshold:    2,
+			InitialDelaySeconds: 15,
+		},
+	}
+	spec := healthCheckSpecFromContainer(container)
+	if spec == nil {
+		t.Fatal("expected a non-nil spec for a container with an HTTPGet livenessProbe")
+	}
+	if spec.Kind != "httpGet" || spec.Path != "/healthz" || spec.Port != 8080 {
+		t.Errorf("got kind=%q path=%q port=%d, want httpGet /healthz 8080", spec.Kind, spec.Path, spec.Port)
+	}
+	if spec.IntervalSeconds != 10 || spec.TimeoutSeconds != 5 || spec.Retries != 2 || spec.StartPeriodSeconds != 15 {
+		t.Errorf("probe fields did not override defaults: %+v", spec)
+	}
+}
+
+func TestHealthCheckSpecFromContainerNoExecOrHTTPGet(t *testing.T) {
+	container := &api.Container{
+		LivenessProbe: &api.Probe{
+			Handler: api.Handler{
+				TCPSocket: &api.TCPSocketAction{Port: intstr.FromInt(80)},
+			},
+		},
+	}
+	if spec := healthCheckSpecFromContainer(container); spec != nil {
+		t.Errorf("expected nil spec for a TCPSocket probe (not natively runnable), got %+v", spec)
+	}
+}
+
+func TestContainerHealthRecordRingBufferAndPreviousStatus(t *testing.T) {
+	h := &containerHealth{status: HealthStatusStarting, stop: make(chan struct{})}
+
+	previous := h.record(HealthCheckResult{Status: HealthStatusHealthy, Time: time.Unix(0, 0)})
+	if previous != HealthStatusStarting {
+		t.Errorf("first record() returned previous=%v, want %v", previous, HealthStatusStarting)
+	}
+
+	for i := 0; i < healthLogSize+3; i++ {
+		h.record(HealthCheckResult{Status: HealthStatusHealthy, Time: time.Unix(int64(i), 0)})
+	}
+	log := h.snapshot()
+	if len(log) != healthLogSize {
+		t.Fatalf("len(log) = %d, want %d", len(log), healthLogSize)
+	}
+	if log[len(log)-1].Time != time.Unix(int64(healthLogSize+2), 0) {
+		t.Errorf("ring buffer did not retain the most recent result: %+v", log[len(log)-1])
+	}
+}
+
+func TestHealthCheckRunnerStartStopIsIdempotent(t *testing.T) {
+	r := NewHealthCheckRunner(nil)
+	containerID := kubecontainer.ContainerID{Type: "docker", ID: "container-1"}
+
+	// Start with a nil spec is a no-op.
+	r.Start(containerID, nil)
+	if log := r.GetHealthLog(containerID); log != nil {
+		t.Errorf("expected no health log for a container with no schedule, got %+v", log)
+	}
+
+	spec := &healthCheckSpec{Kind: "exec", Cmd: []string{"true"}, IntervalSeconds: 3600, StartPeriodSeconds: 3600}
+	r.Start(containerID, spec)
+	r.Start(containerID, spec) // second Start() for the same container is a no-op
+
+	r.Stop(containerID)
+	r.Stop(containerID) // second Stop() for an already-stopped container is a no-op
+}
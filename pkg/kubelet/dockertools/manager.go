@@ -17,7 +17,10 @@ limitations under the License.
 package dockertools
 
 import (
+	"archive/tar"
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -25,10 +28,12 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/coreos/go-semver/semver"
@@ -39,11 +44,18 @@ import (
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/client/record"
+	"k8s.io/kubernetes/pkg/kubelet/dockertools/apparmor"
+	"k8s.io/kubernetes/pkg/kubelet/dockertools/bridge"
+	"k8s.io/kubernetes/pkg/kubelet/dockertools/cgroups"
+	"k8s.io/kubernetes/pkg/kubelet/dockertools/networkns"
+	"k8s.io/kubernetes/pkg/kubelet/dockertools/seccomp"
+	"k8s.io/kubernetes/pkg/kubelet/dockertools/sysctl"
 	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
 	"k8s.io/kubernetes/pkg/kubelet/lifecycle"
 	"k8s.io/kubernetes/pkg/kubelet/metrics"
 	"k8s.io/kubernetes/pkg/kubelet/network"
 	"k8s.io/kubernetes/pkg/kubelet/network/hairpin"
+	"k8s.io/kubernetes/pkg/kubelet/pod"
 	proberesults "k8s.io/kubernetes/pkg/kubelet/prober/results"
 	"k8s.io/kubernetes/pkg/kubelet/qos"
 	kubetypes "k8s.io/kubernetes/pkg/kubelet/types"
@@ -56,6 +68,7 @@ import (
 	"k8s.io/kubernetes/pkg/util/procfs"
 	utilruntime "k8s.io/kubernetes/pkg/util/runtime"
 	"k8s.io/kubernetes/pkg/util/sets"
+	"k8s.io/kubernetes/pkg/util/wait"
 	utilstrings "k8s.io/kubernetes/pkg/util/strings"
 )
 
@@ -66,6 +79,12 @@ const (
 
 	maxReasonCacheEntries = 200
 
+	// maxImageUserCacheEntries bounds imageUserCache the same way maxReasonCacheEntries bounds
+	// reasonCache: an LRU eviction is a cache miss on the next isImageRoot call, not a
+	// correctness problem, so a modest cap is enough to avoid unbounded growth across the many
+	// distinct images a long-lived node accumulates.
+	maxImageUserCacheEntries = 100
+
 	// ndots specifies the minimum number of dots that a domain name must contain for the resolver to consider it as FQDN (fully-qualified)
 	// we want to able to consider SRV lookup names like _dns._udp.kube-dns.default.svc to be considered relative.
 	// hence, setting ndots to be 5.
@@ -83,6 +102,18 @@ const (
 	// networking). Must match the value returned by docker inspect -f
 	// '{{.HostConfig.NetworkMode}}'.
 	namespaceModeHost = "host"
+
+	// HairpinVeth sets the hairpin flag on each container's veth interface, so traffic it sends
+	// to its own Service VIP is bridged back to it.
+	HairpinVeth = "hairpin-veth"
+	// HairpinPromiscuousBridge instead sets the whole container bridge promiscuous, achieving the
+	// same effect when per-veth hairpin mode isn't available (e.g. some older kernels/bridges).
+	HairpinPromiscuousBridge = "promiscuous-bridge"
+	// HairpinNone disables hairpin NAT setup.
+	HairpinNone = "none"
+
+	// defaultBridgeName is the container bridge HairpinPromiscuousBridge mode operates on.
+	defaultBridgeName = "cbr0"
 )
 
 // DockerManager implements the Runtime interface.
@@ -100,6 +131,17 @@ type DockerManager struct {
 
 	// The image name of the pod infra container.
 	podInfraContainerImage string
+
+	// podInfraContainerEnv is appended to every pod infra container's environment, set via
+	// the kubelet's --pod-infra-container-env flag.
+	podInfraContainerEnv []api.EnvVar
+
+	// experimentalAllowedUnsafeSysctls lists the unsafe sysctls (exact names, or "*"-suffixed
+	// prefixes) pods on this node are allowed to request via the unsafe-sysctls annotation,
+	// set via the kubelet's --experimental-allowed-unsafe-sysctls flag. Unsafe sysctls not on
+	// this list are rejected.
+	experimentalAllowedUnsafeSysctls []string
+
 	// reasonCache stores the failure reason of the last container creation
 	// and/or start in a string, keyed by <pod_UID>_<container_name>. The goal
 	// is to propagate this reason to the container status. This endeavor is
@@ -120,6 +162,16 @@ type DockerManager struct {
 	// Root of the Docker runtime.
 	dockerRoot string
 
+	// podCheckpoints persists restart counts and last termination state per (pod UID,
+	// container name) under dockerRoot/checkpoints, so they survive both a kubelet restart
+	// and garbage collection of the dead container instance they'd otherwise be read from.
+	podCheckpoints *PodCheckpointManager
+
+	// imageUserCache caches resolved (image ID, non-numeric USER) -> uid lookups performed by
+	// isImageRoot, so a throwaway container isn't spun up to re-read the same image's
+	// /etc/passwd on every pod sync. Invalidated wholesale by PullImage.
+	imageUserCache imageUserCache
+
 	// Directory of container logs.
 	containerLogsDir string
 
@@ -138,17 +190,65 @@ type DockerManager struct {
 	// Handler used to execute commands in containers.
 	execHandler ExecHandler
 
+	// Tracks live docker exec sessions so attach/detach/resize can reuse
+	// one exec across HTTP reconnects instead of RunInContainer creating
+	// a fresh one-shot exec per call.
+	execSessions *ExecSessionManager
+
+	// Runs container livenessProbes natively instead of relying on an
+	// image's own Docker HEALTHCHECK directive.
+	healthCheckRunner *HealthCheckRunner
+
+	// Polls and publishes Docker-native HEALTHCHECK results for containers whose
+	// livenessProbe opts into them via Handler.DockerHealthCheck.
+	dockerHealthCheckManager *DockerHealthCheckManager
+
 	// Used to set OOM scores of processes.
 	oomAdjuster *oom.OOMAdjuster
 
 	// Get information from /proc mount.
 	procFs procfs.ProcFSInterface
 
+	// cgroupDriver is the cgroup driver the Docker daemon was detected (or configured) to use:
+	// "cgroupfs" or "systemd".
+	cgroupDriver string
+
+	// cgroups computes container cgroup names for cgroupDriver, so cgroup-path construction
+	// doesn't have to assume a particular layout.
+	cgroups cgroups.Driver
+
 	// If true, enforce container cpu limits with CFS quota support
 	cpuCFSQuota bool
 
 	// Container GC manager
 	containerGC *containerGC
+
+	// Validates and loads AppArmor profiles requested via pod annotations.
+	apparmorValidator *apparmor.Validator
+
+	// Resolves seccomp profiles requested via pod annotations.
+	seccompResolver *seccomp.Resolver
+
+	// hairpinMode selects how the pod infra container's hairpin NAT is configured: HairpinVeth,
+	// HairpinPromiscuousBridge, or HairpinNone.
+	hairpinMode string
+
+	// bridgeEnsurer implements HairpinPromiscuousBridge mode by setting the container bridge
+	// promiscuous.
+	bridgeEnsurer bridgePromiscuityEnsurer
+
+	// runtimeAdapter is the runtime-agnostic backend selected via
+	// --container-runtime. It is being migrated in incrementally; most of
+	// DockerManager still talks to client directly, but new code (and the
+	// Podman backend) should grow through this interface rather than
+	// taking a hard dependency on DockerInterface.
+	runtimeAdapter ContainerRuntimeAdapter
+}
+
+// bridgePromiscuityEnsurer is implemented by bridge.Ensurer; kept as an interface so tests can
+// substitute a fake rather than shelling out to `ip link`.
+type bridgePromiscuityEnsurer interface {
+	EnsurePromiscuous(bridgeName string) error
 }
 
 func NewDockerManager(
@@ -170,11 +270,24 @@ func NewDockerManager(
 	procFs procfs.ProcFSInterface,
 	cpuCFSQuota bool,
 	imageBackOff *util.Backoff,
-	serializeImagePulls bool) *DockerManager {
+	serializeImagePulls bool,
+	runtimeAdapter ContainerRuntimeAdapter,
+	kubeletRootDir string,
+	hairpinMode string,
+	cgroupDriver string,
+	podInfraContainerEnv []api.EnvVar,
+	experimentalAllowedUnsafeSysctls []string) (*DockerManager, error) {
+
+	// Fall back to the docker backend if the kubelet wasn't built with
+	// --container-runtime=podman (or some other adapter) wired in.
+	if runtimeAdapter == nil {
+		runtimeAdapter = NewDockerRuntimeAdapter(client, qps, burst)
+	}
 
 	// Work out the location of the Docker runtime, defaulting to /var/lib/docker
 	// if there are any problems.
 	dockerRoot := "/var/lib/docker"
+	detectedCgroupDriver := "cgroupfs"
 	dockerInfo, err := client.Info()
 	if err != nil {
 		glog.Errorf("Failed to execute Info() call to the Docker client: %v", err)
@@ -200,29 +313,75 @@ func NewDockerManager(
 			}
 
 		}
+		if driver := dockerInfo.Get("CgroupDriver"); driver != "" {
+			detectedCgroupDriver = driver
+		}
+	}
+
+	if cgroupDriver == "" {
+		cgroupDriver = detectedCgroupDriver
+	} else if cgroupDriver != detectedCgroupDriver {
+		return nil, fmt.Errorf("configured cgroup driver %q does not match the cgroup driver %q reported by the Docker daemon", cgroupDriver, detectedCgroupDriver)
+	}
+	cgroupsDriver, err := cgroups.New(cgroupDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	podCheckpoints, err := NewPodCheckpointManager(dockerRoot)
+	if err != nil {
+		glog.Errorf("Failed to set up pod checkpoint directory under %s, restart counts will not survive garbage collection of dead containers or a kubelet restart: %v", dockerRoot, err)
 	}
 
 	reasonCache := reasonInfoCache{cache: lru.New(maxReasonCacheEntries)}
+	if kubeletRootDir != "" {
+		journal, err := newReasonCacheJournal(kubeletRootDir)
+		if err != nil {
+			glog.Errorf("Failed to open reason cache journal under %s, failure reasons will not survive a kubelet restart: %v", kubeletRootDir, err)
+		} else {
+			reasonCache.journal = journal
+			if err := journal.Load(&reasonCache); err != nil {
+				glog.Errorf("Failed to hydrate reason cache from journal: %v", err)
+			}
+		}
+	}
 
 	dm := &DockerManager{
-		client:                 client,
-		recorder:               recorder,
-		containerRefManager:    containerRefManager,
-		os:                     osInterface,
-		machineInfo:            machineInfo,
-		podInfraContainerImage: podInfraContainerImage,
-		reasonCache:            reasonCache,
-		dockerPuller:           newDockerPuller(client, qps, burst),
-		dockerRoot:             dockerRoot,
-		containerLogsDir:       containerLogsDir,
-		networkPlugin:          networkPlugin,
-		livenessManager:        livenessManager,
-		runtimeHelper:          runtimeHelper,
-		execHandler:            execHandler,
-		oomAdjuster:            oomAdjuster,
-		procFs:                 procFs,
-		cpuCFSQuota:            cpuCFSQuota,
-	}
+		client:                           client,
+		recorder:                         recorder,
+		containerRefManager:              containerRefManager,
+		os:                               osInterface,
+		machineInfo:                      machineInfo,
+		podInfraContainerImage:           podInfraContainerImage,
+		podInfraContainerEnv:             podInfraContainerEnv,
+		experimentalAllowedUnsafeSysctls: experimentalAllowedUnsafeSysctls,
+		reasonCache:                      reasonCache,
+		dockerPuller:                     newDockerPuller(client, qps, burst),
+		dockerRoot:                       dockerRoot,
+		podCheckpoints:                   podCheckpoints,
+		imageUserCache:                   imageUserCache{cache: lru.New(maxImageUserCacheEntries)},
+		containerLogsDir:                 containerLogsDir,
+		networkPlugin:                    networkPlugin,
+		livenessManager:                  livenessManager,
+		runtimeHelper:                    runtimeHelper,
+		execHandler:                      execHandler,
+		execSessions:                     NewExecSessionManager(client),
+		oomAdjuster:                      oomAdjuster,
+		procFs:                           procFs,
+		cpuCFSQuota:                      cpuCFSQuota,
+		runtimeAdapter:                   runtimeAdapter,
+		apparmorValidator:                apparmor.NewValidator("/sys/kernel/security/apparmor", ""),
+		seccompResolver:                  seccomp.NewResolver(""),
+		hairpinMode:                      hairpinMode,
+		bridgeEnsurer:                    bridge.NewEnsurer(),
+		cgroupDriver:                     cgroupDriver,
+		cgroups:                          cgroupsDriver,
+	}
+	dm.healthCheckRunner = NewHealthCheckRunner(dm)
+	if err := dm.healthCheckRunner.RehydrateHealthChecks(); err != nil {
+		glog.Errorf("Failed to rehydrate native health check schedules: %v", err)
+	}
+	dm.dockerHealthCheckManager = NewDockerHealthCheckManager(dm)
 	dm.runner = lifecycle.NewHandlerRunner(httpClient, dm, dm)
 	if serializeImagePulls {
 		dm.imagePuller = kubecontainer.NewSerializedImagePuller(kubecontainer.FilterEventRecorder(recorder), dm, imageBackOff)
@@ -231,14 +390,30 @@ func NewDockerManager(
 	}
 	dm.containerGC = NewContainerGC(client, containerLogsDir)
 
-	return dm
+	return dm, nil
+}
+
+// CgroupDriver returns the cgroup driver ("cgroupfs" or "systemd") this DockerManager was
+// configured to use, matching the Docker daemon's own --exec-opt native.cgroupdriver setting.
+func (dm *DockerManager) CgroupDriver() string {
+	return dm.cgroupDriver
 }
 
 // A cache which stores strings keyed by <pod_UID>_<container_name>.
+//
+// Entries also flow through an optional journal (see
+// reason_cache_journal.go) so a kubelet restart can hydrate the cache
+// instead of starting out empty.
 type reasonInfoCache struct {
 	lock  sync.RWMutex
 	cache *lru.Cache
+	// uidByKey tracks which pod UID produced each key, so a background
+	// sweep can find entries for pods the podManager no longer knows
+	// about. The LRU cache itself doesn't support iterating its keys.
+	uidByKey map[string]types.UID
+	journal  *reasonCacheJournal
 }
+
 type reasonInfo struct {
 	reason  string
 	message string
@@ -251,13 +426,63 @@ func (sc *reasonInfoCache) composeKey(uid types.UID, name string) string {
 func (sc *reasonInfoCache) Add(uid types.UID, name string, reason, message string) {
 	sc.lock.Lock()
 	defer sc.lock.Unlock()
-	sc.cache.Add(sc.composeKey(uid, name), reasonInfo{reason, message})
+	key := sc.composeKey(uid, name)
+	sc.addByKeyLocked(key, uid, reason, message)
+	if sc.journal != nil {
+		sc.journal.Add(uid, name, reason, message)
+	}
 }
 
 func (sc *reasonInfoCache) Remove(uid types.UID, name string) {
 	sc.lock.Lock()
 	defer sc.lock.Unlock()
-	sc.cache.Remove(sc.composeKey(uid, name))
+	key := sc.composeKey(uid, name)
+	sc.cache.Remove(key)
+	delete(sc.uidByKey, key)
+	if sc.journal != nil {
+		sc.journal.Remove(uid, name)
+	}
+}
+
+func (sc *reasonInfoCache) addByKeyLocked(key string, uid types.UID, reason, message string) {
+	sc.cache.Add(key, reasonInfo{reason, message})
+	if sc.uidByKey == nil {
+		sc.uidByKey = make(map[string]types.UID)
+	}
+	sc.uidByKey[key] = uid
+}
+
+// addByKey hydrates a single record read back from the journal. The UID is
+// recovered from the key's "<uid>_<name>" encoding.
+func (sc *reasonInfoCache) addByKey(key, reason, message string) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	uid := types.UID(key)
+	if idx := strings.LastIndex(key, "_"); idx >= 0 {
+		uid = types.UID(key[:idx])
+	}
+	sc.addByKeyLocked(key, uid, reason, message)
+}
+
+func (sc *reasonInfoCache) removeByKey(key string) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	sc.cache.Remove(key)
+	delete(sc.uidByKey, key)
+}
+
+// keysForUIDsNotIn returns the cache keys whose pod UID is not present in
+// liveUIDs, for use by the journal's background pruning sweep.
+func (sc *reasonInfoCache) keysForUIDsNotIn(liveUIDs map[types.UID]bool) []string {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	var stale []string
+	for key, uid := range sc.uidByKey {
+		if !liveUIDs[uid] {
+			stale = append(stale, key)
+		}
+	}
+	return stale
 }
 
 func (sc *reasonInfoCache) Get(uid types.UID, name string) (reasonInfo, bool) {
@@ -271,6 +496,42 @@ func (sc *reasonInfoCache) Get(uid types.UID, name string) (reasonInfo, bool) {
 	}
 }
 
+// imageUserCache caches the uid isImageRoot resolved for a non-numeric USER directive, keyed
+// by (image ID, user), so repeated pod syncs against the same image don't each pay for a
+// throwaway container just to re-read its /etc/passwd.
+type imageUserCache struct {
+	lock  sync.Mutex
+	cache *lru.Cache
+}
+
+func (c *imageUserCache) key(imageID, user string) string {
+	return imageID + "/" + user
+}
+
+func (c *imageUserCache) Get(imageID, user string) (int, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	value, ok := c.cache.Get(c.key(imageID, user))
+	if !ok {
+		return 0, false
+	}
+	return value.(int), true
+}
+
+func (c *imageUserCache) Add(imageID, user string, uid int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache.Add(c.key(imageID, user), uid)
+}
+
+// Clear discards every cached lookup. Called after a successful PullImage, since a re-pulled
+// image can replace the passwd entries a stale cache entry was resolved from.
+func (c *imageUserCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache = lru.New(maxImageUserCacheEntries)
+}
+
 // GetContainerLogs returns logs of a specific container. By
 // default, it returns a snapshot of the container log. Set 'follow' to true to
 // stream the log. Set 'follow' to false and specify the number of lines (e.g.
@@ -316,6 +577,56 @@ var (
 	ErrContainerCannotRun = errors.New("ContainerCannotRun")
 )
 
+// containerCgroupName returns the cgroup name Docker placed containerID under, using dm.cgroups
+// so the result is correct for whichever cgroup driver the daemon is running with.
+func (dm *DockerManager) containerCgroupName(pod *api.Pod, containerID string) string {
+	return dm.cgroups.ContainerCgroupName(string(qos.GetPodQOS(pod)), string(pod.UID), containerID)
+}
+
+// setupHairpin configures hairpin NAT for a pod's just-started infra container according to
+// dm.hairpinMode, so that a container reaching its own Service VIP gets routed back to itself.
+func (dm *DockerManager) setupHairpin(podInfraContainer *docker.Container) error {
+	switch dm.hairpinMode {
+	case HairpinVeth:
+		return hairpin.SetUpContainer(podInfraContainer.State.Pid, "eth0")
+	case HairpinPromiscuousBridge:
+		return dm.bridgeEnsurer.EnsurePromiscuous(defaultBridgeName)
+	case HairpinNone, "":
+		return nil
+	default:
+		return fmt.Errorf("unknown hairpin mode %q", dm.hairpinMode)
+	}
+}
+
+// applySysctls parses the sysctls pod requests via the sysctls/unsafe-sysctls annotations and
+// merges them into hc.Sysctls. Unsafe sysctls are rejected, and a SysctlForbidden event is
+// recorded against the pod, unless they are explicitly allowlisted via
+// dm.experimentalAllowedUnsafeSysctls.
+func (dm *DockerManager) applySysctls(pod *api.Pod, ref *api.ObjectReference, hc *docker.HostConfig) error {
+	safe, unsafe, err := sysctl.PodSysctls(pod.Annotations)
+	if err != nil {
+		return err
+	}
+	if len(safe) == 0 && len(unsafe) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(safe)+len(unsafe))
+	for name, value := range safe {
+		merged[name] = value
+	}
+	for name, value := range unsafe {
+		if !sysctl.IsAllowed(name, dm.experimentalAllowedUnsafeSysctls) {
+			dm.recorder.Eventf(ref, api.EventTypeWarning, "SysctlForbidden",
+				"Forbidden sysctl %q requested, must be allowlisted via --experimental-allowed-unsafe-sysctls to be set", name)
+			return fmt.Errorf("forbidden sysctl %q is not allowlisted via --experimental-allowed-unsafe-sysctls", name)
+		}
+		merged[name] = value
+	}
+	hc.Sysctls = merged
+	return nil
+}
+
 // determineContainerIP determines the IP address of the given container.  It is expected
 // that the container passed is the infrastructure container of a pod and the responsibility
 // of the caller to ensure that the correct container is passed.
@@ -327,7 +638,7 @@ func (dm *DockerManager) determineContainerIP(podNamespace, podName string, cont
 	}
 
 	if dm.networkPlugin.Name() != network.DefaultPluginName {
-		netStatus, err := dm.networkPlugin.Status(podNamespace, podName, kubecontainer.DockerID(container.ID))
+		netStatus, err := dm.networkPlugin.GetPodNetworkStatus(podNamespace, podName, kubecontainer.DockerID(container.ID))
 		if err != nil {
 			glog.Errorf("NetworkPlugin %s failed on the status hook for pod '%s' - %v", dm.networkPlugin.Name(), podName, err)
 		} else if netStatus != nil {
@@ -557,9 +868,24 @@ func makeEnvList(envs []kubecontainer.EnvVar) (result []string) {
 // '<HostPath>:<ContainerPath>:ro', if the path is read only, or
 // '<HostPath>:<ContainerPath>:Z', if the volume requires SELinux
 // relabeling and the pod provides an SELinux label
-func makeMountBindings(mounts []kubecontainer.Mount, podHasSELinuxLabel bool) (result []string) {
+//
+// When m.SubPath is set, the bind mounts m.HostPath+m.SubPath instead of
+// the volume root, so a single volume can be shared with several
+// containers each seeing only their own sub-directory or sub-file of it.
+// m.SubPathDir tells resolveSubPath which of the two to create when the
+// subpath doesn't exist yet; it is set by whatever builds the Mount from
+// the pod's VolumeMount (a VolumeMount has no inherent file/dir type of
+// its own, so the volume plugin that owns the mount's content decides).
+func makeMountBindings(mounts []kubecontainer.Mount, podHasSELinuxLabel bool) (result []string, err error) {
 	for _, m := range mounts {
-		bind := fmt.Sprintf("%s:%s", m.HostPath, m.ContainerPath)
+		hostPath := m.HostPath
+		if m.SubPath != "" {
+			hostPath, err = resolveSubPath(m.HostPath, m.SubPath, m.SubPathDir)
+			if err != nil {
+				return nil, fmt.Errorf("error resolving subpath %q of %q: %v", m.SubPath, m.HostPath, err)
+			}
+		}
+		bind := fmt.Sprintf("%s:%s", hostPath, m.ContainerPath)
 		if m.ReadOnly {
 			bind += ":ro"
 		}
@@ -579,7 +905,69 @@ func makeMountBindings(mounts []kubecontainer.Mount, podHasSELinuxLabel bool) (r
 		}
 		result = append(result, bind)
 	}
-	return
+	return result, nil
+}
+
+// resolveSubPath joins hostPath and subPath, ensuring the result does not
+// escape hostPath via a symlink or a ".." component, then creates the
+// sub-directory (if isDir) or sub-file (inheriting hostPath's ownership)
+// if it doesn't already exist. It returns the fully resolved path to
+// bind-mount.
+func resolveSubPath(hostPath, subPath string, isDir bool) (string, error) {
+	if filepath.IsAbs(subPath) {
+		return "", fmt.Errorf("subpath %q must be relative", subPath)
+	}
+
+	resolvedHostPath, err := filepath.EvalSymlinks(hostPath)
+	if err != nil {
+		return "", fmt.Errorf("error resolving host path %q: %v", hostPath, err)
+	}
+
+	innerPath := filepath.Join(resolvedHostPath, subPath)
+	if !strings.HasPrefix(innerPath, resolvedHostPath+string(filepath.Separator)) && innerPath != resolvedHostPath {
+		return "", fmt.Errorf("subpath %q escapes the volume %q", subPath, hostPath)
+	}
+
+	if _, err := os.Stat(innerPath); os.IsNotExist(err) {
+		parentInfo, statErr := os.Stat(resolvedHostPath)
+		if statErr != nil {
+			return "", fmt.Errorf("error stating volume root %q: %v", resolvedHostPath, statErr)
+		}
+		if err := os.MkdirAll(filepath.Dir(innerPath), parentInfo.Mode().Perm()); err != nil {
+			return "", fmt.Errorf("error creating parent directories for subpath %q: %v", subPath, err)
+		}
+		if isDir {
+			if err := os.Mkdir(innerPath, parentInfo.Mode().Perm()); err != nil {
+				return "", fmt.Errorf("error creating subpath %q: %v", subPath, err)
+			}
+		} else {
+			f, err := os.OpenFile(innerPath, os.O_CREATE, parentInfo.Mode().Perm())
+			if err != nil {
+				return "", fmt.Errorf("error creating subpath %q: %v", subPath, err)
+			}
+			f.Close()
+		}
+		if stat, ok := parentInfo.Sys().(*syscall.Stat_t); ok {
+			if err := os.Chown(innerPath, int(stat.Uid), int(stat.Gid)); err != nil {
+				glog.Warningf("Unable to set ownership of subpath %q to match volume %q: %v", innerPath, resolvedHostPath, err)
+			}
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("error checking subpath %q: %v", innerPath, err)
+	} else {
+		// Re-resolve through the final component in case it is itself a
+		// symlink, and re-check containment.
+		finalPath, err := filepath.EvalSymlinks(innerPath)
+		if err != nil {
+			return "", fmt.Errorf("error resolving subpath %q: %v", innerPath, err)
+		}
+		if !strings.HasPrefix(finalPath, resolvedHostPath+string(filepath.Separator)) && finalPath != resolvedHostPath {
+			return "", fmt.Errorf("subpath %q escapes the volume %q via a symlink", subPath, hostPath)
+		}
+		innerPath = finalPath
+	}
+
+	return innerPath, nil
 }
 
 func makePortsAndBindings(portMappings []kubecontainer.PortMapping) (map[docker.Port]struct{}, map[docker.Port][]docker.PortBinding) {
@@ -669,6 +1057,18 @@ func (dm *DockerManager) runContainer(
 			glog.Errorf("Failed to encode pod: %s for prestop hook", pod.Name)
 		}
 	}
+
+	// Record the native health check schedule, if the container has a
+	// livenessProbe HealthCheckRunner can act on, so a kubelet restart can
+	// rehydrate it via HealthCheckRunner.RehydrateHealthChecks.
+	if spec := healthCheckSpecFromContainer(container); spec != nil {
+		if data, err := json.Marshal(spec); err == nil {
+			labels[healthCheckLabel] = string(data)
+		} else {
+			glog.Errorf("Failed to encode health check schedule for container %s: %v", container.Name, err)
+		}
+	}
+
 	memoryLimit := container.Resources.Limits.Memory().Value()
 	cpuRequest := container.Resources.Requests.Cpu()
 	cpuLimit := container.Resources.Limits.Cpu()
@@ -702,6 +1102,9 @@ func (dm *DockerManager) runContainer(
 			OpenStdin: container.Stdin,
 			StdinOnce: container.StdinOnce,
 			Tty:       container.TTY,
+			// Overrides the image's own HEALTHCHECK cadence when the pod's livenessProbe
+			// requests Docker-native health checking; nil otherwise (image default applies).
+			Healthcheck: dockerHealthConfigFromProbe(container.LivenessProbe),
 		},
 	}
 
@@ -720,7 +1123,10 @@ func (dm *DockerManager) runContainer(
 	dm.recorder.Eventf(ref, api.EventTypeNormal, kubecontainer.CreatedContainer, "Created container with docker id %v", utilstrings.ShortenString(dockerContainer.ID, 12))
 
 	podHasSELinuxLabel := pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.SELinuxOptions != nil
-	binds := makeMountBindings(opts.Mounts, podHasSELinuxLabel)
+	binds, err := makeMountBindings(opts.Mounts, podHasSELinuxLabel)
+	if err != nil {
+		return kubecontainer.ContainerID{}, err
+	}
 
 	// The reason we create and mount the log file in here (not in kubelet) is because
 	// the file's location depends on the ID of the container, and we need to create and
@@ -772,6 +1178,39 @@ func (dm *DockerManager) runContainer(
 	}
 	securityContextProvider.ModifyHostConfig(pod, container, hc)
 
+	if profile := apparmor.GetProfileName(pod.Annotations, container.Name); profile != "" {
+		secOpt, err := dm.apparmorValidator.ValidateProfile(profile)
+		if err != nil {
+			dm.recorder.Eventf(ref, api.EventTypeWarning, kubecontainer.FailedToCreateContainer, "Failed to apply AppArmor profile %q: %v", profile, err)
+			return kubecontainer.ContainerID{}, err
+		}
+		hc.SecurityOpt = append(hc.SecurityOpt, secOpt)
+	}
+
+	seccompProfile := seccomp.ProfileName(pod.Annotations, container.Name)
+	if supported, err := dm.seccompSupported(); err != nil {
+		glog.Warningf("Unable to determine seccomp support, assuming unsupported: %v", err)
+	} else if !supported {
+		if seccompProfile != "" && seccompProfile != seccomp.ProfileUnconfined {
+			dm.recorder.Eventf(ref, api.EventTypeWarning, kubecontainer.FailedToCreateContainer,
+				"Docker API version predates seccomp support (1.10+); falling back to unconfined for profile %q", seccompProfile)
+		}
+	} else if secOpt, err := dm.seccompResolver.Resolve(seccompProfile); err != nil {
+		dm.recorder.Eventf(ref, api.EventTypeWarning, kubecontainer.FailedToCreateContainer, "Failed to apply seccomp profile %q: %v", seccompProfile, err)
+		return kubecontainer.ContainerID{}, err
+	} else if secOpt != "" {
+		hc.SecurityOpt = append(hc.SecurityOpt, secOpt)
+	}
+
+	// Sysctls are namespaced: only the container that owns the net/ipc namespaces (the pod
+	// infra container) needs to apply them. App containers join those namespaces and inherit
+	// the result.
+	if container.Name == PodInfraContainerName {
+		if err := dm.applySysctls(pod, ref, hc); err != nil {
+			return kubecontainer.ContainerID{}, err
+		}
+	}
+
 	if err = dm.client.StartContainer(dockerContainer.ID, hc); err != nil {
 		dm.recorder.Eventf(ref, api.EventTypeWarning, kubecontainer.FailedToStartContainer,
 			"Failed to start container with docker id %v with error: %v", utilstrings.ShortenString(dockerContainer.ID, 12), err)
@@ -903,7 +1342,14 @@ func (dm *DockerManager) ListImages() ([]kubecontainer.Image, error) {
 // TODO(vmarmol): Consider unexporting.
 // PullImage pulls an image from network to local storage.
 func (dm *DockerManager) PullImage(image kubecontainer.ImageSpec, secrets []api.Secret) error {
-	return dm.dockerPuller.Pull(image.Image, secrets)
+	err := dm.dockerPuller.Pull(image.Image, secrets)
+	if err == nil {
+		// A re-pulled image can land under the same reference with a different rootfs (and
+		// therefore a different /etc/passwd), so isImageRoot's cache can't be trusted to still
+		// match reality.
+		dm.imageUserCache.Clear()
+	}
+	return err
 }
 
 // IsImagePresent checks whether the container image is already in the local storage.
@@ -1012,7 +1458,7 @@ func (dv dockerAPIVersion) Compare(other string) (int, error) {
 }
 
 func (dm *DockerManager) Type() string {
-	return DockerType
+	return string(dm.runtimeAdapter.Name())
 }
 
 func (dm *DockerManager) Version() (kubecontainer.Version, error) {
@@ -1060,6 +1506,18 @@ func (dm *DockerManager) nativeExecSupportExists() (bool, error) {
 	return false, err
 }
 
+// seccompSupported reports whether the docker daemon is new enough to
+// understand the seccomp SecurityOpt, using the same
+// APIVersion()/Compare() pattern nativeExecSupportExists uses to gate exec
+// support.
+func (dm *DockerManager) seccompSupported() (bool, error) {
+	version, err := dm.APIVersion()
+	if err != nil {
+		return false, err
+	}
+	return seccomp.SupportsSeccomp(version.Compare)
+}
+
 func (dm *DockerManager) getRunInContainerCommand(containerID kubecontainer.ContainerID, cmd []string) (*exec.Cmd, error) {
 	args := append([]string{"exec"}, cmd...)
 	command := exec.Command("/usr/sbin/nsinit", args...)
@@ -1087,48 +1545,21 @@ func (dm *DockerManager) RunInContainer(containerID kubecontainer.ContainerID, c
 		return dm.runInContainerUsingNsinit(containerID, cmd)
 	}
 	glog.V(2).Infof("Using docker native exec to run cmd %+v inside container %s", cmd, containerID)
-	createOpts := docker.CreateExecOptions{
-		Container:    containerID.ID,
-		Cmd:          cmd,
-		AttachStdin:  false,
-		AttachStdout: true,
-		AttachStderr: true,
-		Tty:          false,
-	}
-	execObj, err := dm.client.CreateExec(createOpts)
+	var buf bytes.Buffer
+	sessionID, err := dm.execSessions.Create(containerID, cmd, nil, &buf, &buf, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run in container - Exec setup failed - %v", err)
 	}
-	var buf bytes.Buffer
-	startOpts := docker.StartExecOptions{
-		Detach:       false,
-		Tty:          false,
-		OutputStream: &buf,
-		ErrorStream:  &buf,
-		RawTerminal:  false,
-	}
-	err = dm.client.StartExec(execObj.ID, startOpts)
+	exitCode, err := dm.execSessions.Wait(sessionID)
 	if err != nil {
-		glog.V(2).Infof("StartExec With error: %v", err)
-		return nil, err
+		glog.V(2).Infof("exec session %s failed with error: %+v", sessionID, err)
+		return buf.Bytes(), err
 	}
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-	for {
-		inspect, err2 := dm.client.InspectExec(execObj.ID)
-		if err2 != nil {
-			glog.V(2).Infof("InspectExec %s failed with error: %+v", execObj.ID, err2)
-			return buf.Bytes(), err2
-		}
-		if !inspect.Running {
-			if inspect.ExitCode != 0 {
-				glog.V(2).Infof("InspectExec %s exit with result %+v", execObj.ID, inspect)
-				err = &dockerExitError{inspect}
-			}
-			break
-		}
-		<-ticker.C
+	if exitCode != 0 {
+		glog.V(2).Infof("exec session %s exited with code %d", sessionID, exitCode)
+		err = &dockerExitError{&docker.ExecInspect{ExitCode: exitCode}}
 	}
+	dm.execSessions.Prune()
 
 	return buf.Bytes(), err
 }
@@ -1170,6 +1601,48 @@ func (dm *DockerManager) ExecInContainer(containerID kubecontainer.ContainerID,
 	return dm.execHandler.ExecInContainer(dm.client, container, cmd, stdin, stdout, stderr, tty)
 }
 
+// CreateExecSession starts a persistent exec session in containerID and
+// returns a session ID that can be re-attached to (via AttachExecSession)
+// across HTTP reconnects, resized (via ResizeExecSession), or inspected
+// after it completes, unlike the one-shot exec ExecInContainer creates.
+//
+// TODO: kubecontainer.Runtime doesn't yet declare these methods; once it
+// does, the kubelet streaming server's attach/resize handlers should call
+// through the interface instead of type-asserting to *DockerManager.
+func (dm *DockerManager) CreateExecSession(containerID kubecontainer.ContainerID, cmd []string, stdin io.Reader, stdout, stderr io.Writer, tty bool) (string, error) {
+	return dm.execSessions.Create(containerID, cmd, stdin, stdout, stderr, tty)
+}
+
+// AttachExecSession (re)attaches to a session created by CreateExecSession,
+// streaming until the session completes or the caller's streams close.
+func (dm *DockerManager) AttachExecSession(sessionID string, stdin io.Reader, stdout, stderr io.Writer, tty bool) error {
+	return dm.execSessions.Attach(sessionID, stdin, stdout, stderr, tty)
+}
+
+// DetachExecSession lets a caller walk away from sessionID's streams
+// without terminating the underlying exec, so a later AttachExecSession
+// call can pick it back up.
+func (dm *DockerManager) DetachExecSession(sessionID string) error {
+	return dm.execSessions.Detach(sessionID)
+}
+
+// ResizeExecSession propagates a TTY resize to a live exec session.
+func (dm *DockerManager) ResizeExecSession(sessionID string, height, width int) error {
+	return dm.execSessions.Resize(sessionID, height, width)
+}
+
+// InspectExecSession returns the state and, once stopped, exit code of a
+// session created by CreateExecSession.
+func (dm *DockerManager) InspectExecSession(sessionID string) (ExecSessionState, int, error) {
+	return dm.execSessions.Inspect(sessionID)
+}
+
+// GetHealthLog returns the rolling native health check results recorded
+// for containerID by the HealthCheckRunner, oldest first.
+func (dm *DockerManager) GetHealthLog(containerID kubecontainer.ContainerID) []HealthCheckResult {
+	return dm.healthCheckRunner.GetHealthLog(containerID)
+}
+
 func (dm *DockerManager) AttachContainer(containerID kubecontainer.ContainerID, stdin io.Reader, stdout, stderr io.WriteCloser, tty bool) error {
 	opts := docker.AttachToContainerOptions{
 		Container:    containerID.ID,
@@ -1190,14 +1663,12 @@ func noPodInfraContainerError(podName, podNamespace string) error {
 	return fmt.Errorf("cannot find pod infra container in pod %q", kubecontainer.BuildPodFullName(podName, podNamespace))
 }
 
-// PortForward executes socat in the pod's network namespace and copies
-// data between stream (representing the user's local connection on their
-// computer) and the specified port in the container.
+// PortForward enters the pod's network namespace directly via setns(2)
+// and copies data between stream (representing the user's local
+// connection on their computer) and the specified port in the container.
 //
 // TODO:
 //  - match cgroups of container
-//  - should we support nsenter + socat on the host? (current impl)
-//  - should we support nsenter + socat in a container, running with elevated privs and --pid=host?
 func (dm *DockerManager) PortForward(pod *kubecontainer.Pod, port uint16, stream io.ReadWriteCloser) error {
 	podInfraContainer := pod.FindContainerByName(PodInfraContainerName)
 	if podInfraContainer == nil {
@@ -1212,59 +1683,13 @@ func (dm *DockerManager) PortForward(pod *kubecontainer.Pod, port uint16, stream
 		return fmt.Errorf("container not running (%s)", container.ID)
 	}
 
-	containerPid := container.State.Pid
-	socatPath, lookupErr := exec.LookPath("socat")
-	if lookupErr != nil {
-		return fmt.Errorf("unable to do port forwarding: socat not found.")
-	}
-
-	args := []string{"-t", fmt.Sprintf("%d", containerPid), "-n", socatPath, "-", fmt.Sprintf("TCP4:localhost:%d", port)}
-
-	nsenterPath, lookupErr := exec.LookPath("nsenter")
-	if lookupErr != nil {
-		return fmt.Errorf("unable to do port forwarding: nsenter not found.")
-	}
-
-	commandString := fmt.Sprintf("%s %s", nsenterPath, strings.Join(args, " "))
-	glog.V(4).Infof("executing port forwarding command: %s", commandString)
-
-	command := exec.Command(nsenterPath, args...)
-	command.Stdout = stream
-
-	stderr := new(bytes.Buffer)
-	command.Stderr = stderr
-
-	// If we use Stdin, command.Run() won't return until the goroutine that's copying
-	// from stream finishes. Unfortunately, if you have a client like telnet connected
-	// via port forwarding, as long as the user's telnet client is connected to the user's
-	// local listener that port forwarding sets up, the telnet session never exits. This
-	// means that even if socat has finished running, command.Run() won't ever return
-	// (because the client still has the connection and stream open).
-	//
-	// The work around is to use StdinPipe(), as Wait() (called by Run()) closes the pipe
-	// when the command (socat) exits.
-	inPipe, err := command.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("unable to do port forwarding: error creating stdin pipe: %v", err)
-	}
-	go func() {
-		io.Copy(inPipe, stream)
-		inPipe.Close()
-	}()
-
-	if err := command.Run(); err != nil {
-		return fmt.Errorf("%v: %s", err, stderr.String())
-	}
-
-	return nil
+	glog.V(4).Infof("forwarding port %d into network namespace of pid %d", port, container.State.Pid)
+	return networkns.PortForward(container.State.Pid, port, stream)
 }
 
-// Get the IP address of a container's interface using nsenter
+// GetContainerIP returns the IP address assigned to interfaceName inside
+// the container's network namespace.
 func (dm *DockerManager) GetContainerIP(containerID, interfaceName string) (string, error) {
-	_, lookupErr := exec.LookPath("nsenter")
-	if lookupErr != nil {
-		return "", fmt.Errorf("Unable to obtain IP address of container: missing nsenter.")
-	}
 	container, err := dm.client.InspectContainer(containerID)
 	if err != nil {
 		return "", err
@@ -1274,29 +1699,33 @@ func (dm *DockerManager) GetContainerIP(containerID, interfaceName string) (stri
 		return "", fmt.Errorf("container not running (%s)", container.ID)
 	}
 
-	containerPid := container.State.Pid
-	extractIPCmd := fmt.Sprintf("ip -4 addr show %s | grep inet | awk -F\" \" '{print $2}'", interfaceName)
-	args := []string{"-t", fmt.Sprintf("%d", containerPid), "-n", "--", "bash", "-c", extractIPCmd}
-	command := exec.Command("nsenter", args...)
-	out, err := command.CombinedOutput()
+	addrs, err := networkns.InterfaceAddrs(container.State.Pid, interfaceName)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("unable to obtain IP address of container: %v", err)
 	}
-	return string(out), nil
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("no addresses found for interface %q in container %s", interfaceName, containerID)
+	}
+	return addrs[0].String(), nil
 }
 
 // TODO(random-liu): Change running pod to pod status in the future. We can't do it now, because kubelet also uses this function without pod status.
 // We can only deprecate this after refactoring kubelet.
 // TODO(random-liu): After using pod status for KillPod(), we can also remove the kubernetesPodLabel, because all the needed information should have
 // been extract from new labels and stored in pod status.
-func (dm *DockerManager) KillPod(pod *api.Pod, runningPod kubecontainer.Pod) error {
-	result := dm.killPodWithSyncResult(pod, runningPod)
+// gracePeriodOverride, if non-nil, takes precedence over the pod's own termination grace
+// period when stopping its containers; pass nil to use pod.Spec.TerminationGracePeriodSeconds.
+func (dm *DockerManager) KillPod(pod *api.Pod, runningPod kubecontainer.Pod, gracePeriodOverride *int64) error {
+	result := dm.killPodWithSyncResult(pod, runningPod, nil, gracePeriodOverride)
 	return result.Error()
 }
 
 // TODO(random-liu): This is just a temporary function, will be removed when we acturally add PodSyncResult
 // NOTE(random-liu): The pod passed in could be *nil* when kubelet restarted.
-func (dm *DockerManager) killPodWithSyncResult(pod *api.Pod, runningPod kubecontainer.Pod) (result kubecontainer.PodSyncResult) {
+// initContainersToKeep, if non-nil, excludes the listed init container instances from being killed
+// so their logs remain available; pass nil to kill every container in runningPod.
+// gracePeriodOverride is forwarded to KillContainerInPod for every container killed here.
+func (dm *DockerManager) killPodWithSyncResult(pod *api.Pod, runningPod kubecontainer.Pod, initContainersToKeep map[kubecontainer.DockerID]int, gracePeriodOverride *int64) (result kubecontainer.PodSyncResult) {
 	// Send the kills in parallel since they may take a long time.
 	// There may be len(runningPod.Containers) or len(runningPod.Containers)-1 of result in the channel
 	containerResults := make(chan *kubecontainer.SyncResult, len(runningPod.Containers))
@@ -1311,6 +1740,10 @@ func (dm *DockerManager) killPodWithSyncResult(pod *api.Pod, runningPod kubecont
 			defer utilruntime.HandleCrash()
 			defer wg.Done()
 
+			if _, keep := initContainersToKeep[kubecontainer.DockerID(container.ID.ID)]; keep {
+				return
+			}
+
 			var containerSpec *api.Container
 			if pod != nil {
 				for i, c := range pod.Spec.Containers {
@@ -1332,7 +1765,7 @@ func (dm *DockerManager) killPodWithSyncResult(pod *api.Pod, runningPod kubecont
 			}
 
 			killContainerResult := kubecontainer.NewSyncResult(kubecontainer.KillContainer, container.Name)
-			err := dm.KillContainerInPod(container.ID, containerSpec, pod, "Need to kill pod.")
+			err := dm.KillContainerInPod(container.ID, containerSpec, pod, "Need to kill pod.", gracePeriodOverride)
 			if err != nil {
 				killContainerResult.Fail(kubecontainer.ErrKillContainer, err.Error())
 				glog.Errorf("Failed to delete container: %v; Skipping pod %q", err, runningPod.ID)
@@ -1362,7 +1795,7 @@ func (dm *DockerManager) killPodWithSyncResult(pod *api.Pod, runningPod kubecont
 		}
 		killContainerResult := kubecontainer.NewSyncResult(kubecontainer.KillContainer, networkContainer.Name)
 		result.AddSyncResult(killContainerResult)
-		if err := dm.KillContainerInPod(networkContainer.ID, networkSpec, pod, "Need to kill pod."); err != nil {
+		if err := dm.KillContainerInPod(networkContainer.ID, networkSpec, pod, "Need to kill pod.", gracePeriodOverride); err != nil {
 			killContainerResult.Fail(kubecontainer.ErrKillContainer, err.Error())
 			glog.Errorf("Failed to delete container: %v; Skipping pod %q", err, runningPod.ID)
 		}
@@ -1371,8 +1804,9 @@ func (dm *DockerManager) killPodWithSyncResult(pod *api.Pod, runningPod kubecont
 }
 
 // KillContainerInPod kills a container in the pod. It must be passed either a container ID or a container and pod,
-// and will attempt to lookup the other information if missing.
-func (dm *DockerManager) KillContainerInPod(containerID kubecontainer.ContainerID, container *api.Container, pod *api.Pod, message string) error {
+// and will attempt to lookup the other information if missing. gracePeriodOverride, if non-nil, takes
+// precedence over the pod's own termination grace period.
+func (dm *DockerManager) KillContainerInPod(containerID kubecontainer.ContainerID, container *api.Container, pod *api.Pod, message string, gracePeriodOverride *int64) error {
 	switch {
 	case containerID.IsEmpty():
 		// Locate the container.
@@ -1404,12 +1838,16 @@ func (dm *DockerManager) KillContainerInPod(containerID kubecontainer.ContainerI
 			pod = storedPod
 		}
 	}
-	return dm.killContainer(containerID, container, pod, message)
+	return dm.killContainer(containerID, container, pod, message, gracePeriodOverride)
 }
 
 // killContainer accepts a containerID and an optional container or pod containing shutdown policies. Invoke
-// KillContainerInPod if information must be retrieved first.
-func (dm *DockerManager) killContainer(containerID kubecontainer.ContainerID, container *api.Container, pod *api.Pod, reason string) error {
+// KillContainerInPod if information must be retrieved first. gracePeriodOverride, if non-nil, takes
+// precedence over the pod's own termination grace period.
+func (dm *DockerManager) killContainer(containerID kubecontainer.ContainerID, container *api.Container, pod *api.Pod, reason string, gracePeriodOverride *int64) error {
+	dm.healthCheckRunner.Stop(containerID)
+	dm.dockerHealthCheckManager.Stop(containerID)
+
 	ID := containerID.ID
 	name := ID
 	if container != nil {
@@ -1428,6 +1866,12 @@ func (dm *DockerManager) killContainer(containerID kubecontainer.ContainerID, co
 			gracePeriod = *pod.Spec.TerminationGracePeriodSeconds
 		}
 	}
+	if gracePeriodOverride != nil {
+		gracePeriod = *gracePeriodOverride
+	}
+	if pod != nil {
+		glog.V(4).Infof("Killing container %q in cgroup %q", name, dm.containerCgroupName(pod, ID))
+	}
 	glog.V(2).Infof("Killing container %q with %d second grace period", name, gracePeriod)
 	start := unversioned.Now()
 
@@ -1552,11 +1996,14 @@ func (dm *DockerManager) runContainerInPod(pod *api.Pod, container *api.Containe
 		dm.containerRefManager.SetRef(id, ref)
 	}
 
+	dm.healthCheckRunner.Start(id, healthCheckSpecFromContainer(container))
+	dm.dockerHealthCheckManager.Start(pod, container, id)
+
 	if container.Lifecycle != nil && container.Lifecycle.PostStart != nil {
 		handlerErr := dm.runner.Run(id, pod, container, container.Lifecycle.PostStart)
 		if handlerErr != nil {
 			err := fmt.Errorf("PostStart handler: %v", handlerErr)
-			dm.KillContainerInPod(id, container, pod, err.Error())
+			dm.KillContainerInPod(id, container, pod, err.Error(), nil)
 			return kubecontainer.ContainerID{}, err
 		}
 	}
@@ -1591,10 +2038,7 @@ func (dm *DockerManager) runContainerInPod(pod *api.Pod, container *api.Containe
 	} else {
 		oomScoreAdj = qos.GetContainerOOMScoreAdjust(container, int64(dm.machineInfo.MemoryCapacity))
 	}
-	cgroupName, err := dm.procFs.GetFullContainerName(containerInfo.State.Pid)
-	if err != nil {
-		return kubecontainer.ContainerID{}, fmt.Errorf("GetFullContainerName: %v", err)
-	}
+	cgroupName := dm.containerCgroupName(pod, id.ID)
 	if err = dm.oomAdjuster.ApplyOOMScoreAdjContainer(cgroupName, oomScoreAdj, 5); err != nil {
 		return kubecontainer.ContainerID{}, fmt.Errorf("ApplyOOMScoreAdjContainer: %v", err)
 	}
@@ -1672,6 +2116,7 @@ func (dm *DockerManager) createPodInfraContainer(pod *api.Pod) (kubecontainer.Do
 		Name:            PodInfraContainerName,
 		Image:           dm.podInfraContainerImage,
 		Ports:           ports,
+		Env:             dm.podInfraContainerEnv,
 		ImagePullPolicy: podInfraContainerImagePullPolicy,
 	}
 
@@ -1700,14 +2145,27 @@ func (dm *DockerManager) createPodInfraContainer(pod *api.Pod) (kubecontainer.Do
 //   It shouldn't be the case where containersToStart is empty and containersToKeep contains only infraContainerId. In such case
 //   Infra Container should be killed, hence it's removed from this map.
 // - all running containers which are NOT contained in containersToKeep should be killed.
+// - initFailed is true if an init container has permanently failed (non-zero exit and a
+//   RestartPolicy that doesn't retry it). App containers must not be started while this is true.
+// - initContainersToKeep stores dockerIDs of init container instances (the currently running one,
+//   plus previously succeeded ones) that should survive a kill pass so their logs remain available.
+// - nextInitContainerToStart is the next init container SyncPod should run, or nil if every init
+//   container has succeeded, the chain has permanently failed, or one is already running.
 type podContainerChangesSpec struct {
-	StartInfraContainer bool
-	InfraChanged        bool
-	InfraContainerId    kubecontainer.DockerID
-	ContainersToStart   map[int]string
-	ContainersToKeep    map[kubecontainer.DockerID]int
+	StartInfraContainer      bool
+	InfraChanged             bool
+	InfraContainerId         kubecontainer.DockerID
+	ContainersToStart        map[int]string
+	ContainersToKeep         map[kubecontainer.DockerID]int
+	InitFailed               bool
+	InitContainersToKeep     map[kubecontainer.DockerID]int
+	NextInitContainerToStart *api.Container
 }
 
+// initContainerRetentionCount bounds how many exited instances of a given init container are
+// kept around on the node purely so `kubectl logs --previous` keeps working across restarts.
+const initContainerRetentionCount = 1
+
 func (dm *DockerManager) computePodContainerChanges(pod *api.Pod, podStatus *kubecontainer.PodStatus) (podContainerChangesSpec, error) {
 	start := time.Now()
 	defer func() {
@@ -1807,15 +2265,96 @@ func (dm *DockerManager) computePodContainerChanges(pod *api.Pod, podStatus *kub
 		containersToKeep = make(map[kubecontainer.DockerID]int)
 	}
 
+	// Init containers only matter while the existing Infra Container (and therefore the existing
+	// init container run) is being kept. If the Infra Container is being recreated, every init
+	// container will be run again from scratch in the new sandbox.
+	initContainersToKeep := make(map[kubecontainer.DockerID]int)
+	initFailed := false
+	var nextInitContainer *api.Container
+	if !createPodInfraContainer {
+		initContainersToKeep, initFailed, nextInitContainer = dm.findActiveInitContainer(pod, podStatus)
+	}
+
 	return podContainerChangesSpec{
-		StartInfraContainer: createPodInfraContainer,
-		InfraChanged:        changed,
-		InfraContainerId:    podInfraContainerID,
-		ContainersToStart:   containersToStart,
-		ContainersToKeep:    containersToKeep,
+		StartInfraContainer:      createPodInfraContainer,
+		InfraChanged:             changed,
+		InfraContainerId:         podInfraContainerID,
+		ContainersToStart:        containersToStart,
+		ContainersToKeep:         containersToKeep,
+		InitFailed:               initFailed,
+		InitContainersToKeep:     initContainersToKeep,
+		NextInitContainerToStart: nextInitContainer,
 	}, nil
 }
 
+// findActiveInitContainer walks pod.Spec.InitContainers in order, since they must run serially
+// before any app container starts. It returns the set of init container instances that should be
+// retained across a kill pass (the one currently running, plus any that have already succeeded,
+// so their logs stay available), whether the init container chain has permanently failed, and the
+// next init container SyncPod should (re)start, if any. Only one of "permanently failed" and "next
+// container to start" is ever reported for a given pod: a pod that needs a retry isn't failed yet.
+func (dm *DockerManager) findActiveInitContainer(pod *api.Pod, podStatus *kubecontainer.PodStatus) (map[kubecontainer.DockerID]int, bool, *api.Container) {
+	initContainersToKeep := make(map[kubecontainer.DockerID]int)
+	for index := range pod.Spec.InitContainers {
+		container := &pod.Spec.InitContainers[index]
+		containerStatus := podStatus.FindContainerStatusByName(container.Name)
+		if containerStatus == nil {
+			// This init container, and therefore all those after it, hasn't run yet.
+			return initContainersToKeep, false, container
+		}
+		id := kubecontainer.DockerID(containerStatus.ID.ID)
+		switch {
+		case containerStatus.State == kubecontainer.ContainerStateRunning:
+			// This is the init container currently in flight; keep it and stop walking.
+			initContainersToKeep[id] = index
+			return initContainersToKeep, false, nil
+		case containerStatus.State != kubecontainer.ContainerStateExited:
+			// Still being created, or some other transient state; wait for it to settle.
+			return initContainersToKeep, false, nil
+		case containerStatus.ExitCode == 0:
+			// Succeeded: retain it for its logs and move on to the next init container.
+			initContainersToKeep[id] = index
+			continue
+		default:
+			// Failed. Retain it for its logs regardless of outcome.
+			initContainersToKeep[id] = index
+			if pod.Spec.RestartPolicy == api.RestartPolicyNever {
+				return initContainersToKeep, true, nil
+			}
+			// Always and OnFailure both retry a failed init container; doBackOff (applied by
+			// the caller around the actual restart) paces the retries.
+			return initContainersToKeep, false, container
+		}
+	}
+	// Every init container has succeeded.
+	return initContainersToKeep, false, nil
+}
+
+// pruneInitContainersBeforeStart removes exited init container instances that are neither in
+// initContainersToKeep nor within the small retention window, so repeatedly restarting a failing
+// init container doesn't leak stopped containers on the node.
+func (dm *DockerManager) pruneInitContainersBeforeStart(pod *api.Pod, podStatus *kubecontainer.PodStatus, initContainersToKeep map[kubecontainer.DockerID]int) {
+	keptInstances := make(map[string]int)
+	for _, container := range pod.Spec.InitContainers {
+		for _, status := range podStatus.ContainerStatuses {
+			if status.Name != container.Name || status.State != kubecontainer.ContainerStateExited {
+				continue
+			}
+			if _, keep := initContainersToKeep[kubecontainer.DockerID(status.ID.ID)]; keep {
+				continue
+			}
+			keptInstances[status.Name]++
+			if keptInstances[status.Name] <= initContainerRetentionCount {
+				continue
+			}
+			glog.V(4).Infof("Pruning exited init container %q instance %s for pod %q", status.Name, status.ID, format.Pod(pod))
+			if err := dm.client.RemoveContainer(docker.RemoveContainerOptions{ID: status.ID.ID, RemoveVolumes: true}); err != nil {
+				glog.Warningf("Failed to prune exited init container %q instance %s for pod %q: %v", status.Name, status.ID, format.Pod(pod), err)
+			}
+		}
+	}
+}
+
 // updateReasonCache updates the failure reason based on the registered error.
 func (dm *DockerManager) updateReasonCache(pod *api.Pod, container *api.Container, briefError string, err error) {
 	if briefError == "" || err == nil {
@@ -1830,13 +2369,22 @@ func (dm *DockerManager) clearReasonCache(pod *api.Pod, container *api.Container
 	dm.reasonCache.Remove(pod.UID, container.Name)
 }
 
-// Sync the running pod to match the specified desired pod.
-func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubecontainer.PodStatus, pullSecrets []api.Secret, backOff *util.Backoff) (result kubecontainer.PodSyncResult) {
+// Sync the running pod to match the specified desired pod. When options.UpdateType is
+// kubecontainer.SyncPodKill, this short-circuits straight to KillPod with
+// options.GracePeriodOverride, skipping computePodContainerChanges, backoff, and container
+// start entirely: a pod that's only being deleted has no use for any of that.
+func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubecontainer.PodStatus, pullSecrets []api.Secret, backOff *util.Backoff, options kubecontainer.SyncPodOptions) (result kubecontainer.PodSyncResult) {
 	start := time.Now()
 	defer func() {
 		metrics.ContainerManagerLatency.WithLabelValues("SyncPod").Observe(metrics.SinceInMicroseconds(start))
 	}()
 
+	if options.UpdateType == kubecontainer.SyncPodKill {
+		killResult := dm.killPodWithSyncResult(pod, kubecontainer.ConvertPodStatusToRunningPod(podStatus), nil, options.GracePeriodOverride)
+		result.AddPodSyncResult(killResult)
+		return
+	}
+
 	containerChanges, err := dm.computePodContainerChanges(pod, podStatus)
 	if err != nil {
 		result.Fail(err)
@@ -1860,7 +2408,7 @@ func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubec
 
 		// Killing phase: if we want to start new infra container, or nothing is running kill everything (including infra container)
 		// TODO(random-liu): We'll use pod status directly in the future
-		killResult := dm.killPodWithSyncResult(pod, kubecontainer.ConvertPodStatusToRunningPod(podStatus))
+		killResult := dm.killPodWithSyncResult(pod, kubecontainer.ConvertPodStatusToRunningPod(podStatus), containerChanges.InitContainersToKeep, options.GracePeriodOverride)
 		result.AddPodSyncResult(killResult)
 		if killResult.Error() != nil {
 			return
@@ -1884,7 +2432,7 @@ func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubec
 				}
 				killContainerResult := kubecontainer.NewSyncResult(kubecontainer.KillContainer, containerStatus.Name)
 				result.AddSyncResult(killContainerResult)
-				if err := dm.KillContainerInPod(containerStatus.ID, podContainer, pod, killMessage); err != nil {
+				if err := dm.KillContainerInPod(containerStatus.ID, podContainer, pod, killMessage, nil); err != nil {
 					killContainerResult.Fail(kubecontainer.ErrKillContainer, err.Error())
 					glog.Errorf("Error killing container %q(id=%q) for pod %q: %v", containerStatus.Name, containerStatus.ID, format.Pod(pod), err)
 					return
@@ -1923,7 +2471,7 @@ func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubec
 				result.AddSyncResult(killContainerResult)
 				if delErr := dm.KillContainerInPod(kubecontainer.ContainerID{
 					ID:   string(podInfraContainerID),
-					Type: "docker"}, nil, pod, message); delErr != nil {
+					Type: "docker"}, nil, pod, message, nil); delErr != nil {
 					killContainerResult.Fail(kubecontainer.ErrKillContainer, delErr.Error())
 					glog.Warningf("Clear infra container failed for pod %q: %v", format.Pod(pod), delErr)
 				}
@@ -1931,7 +2479,6 @@ func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubec
 			}
 		}
 
-		// Setup the host interface unless the pod is on the host's network (FIXME: move to networkPlugin when ready)
 		var podInfraContainer *docker.Container
 		podInfraContainer, err = dm.client.InspectContainer(string(podInfraContainerID))
 		if err != nil {
@@ -1939,8 +2486,11 @@ func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubec
 			result.Fail(err)
 			return
 		}
-		if !usesHostNetwork(pod) {
-			if err = hairpin.SetUpContainer(podInfraContainer.State.Pid, "eth0"); err != nil {
+		// A CNI/kubenet-style plugin already did its own veth discovery and hairpin_mode write
+		// as part of SetUpPod above; dm only still owns hairpin setup for the default (plain
+		// Docker bridge) networking, where there is no plugin to delegate it to.
+		if !usesHostNetwork(pod) && dm.networkPlugin.Name() == network.DefaultPluginName {
+			if err := dm.setupHairpin(podInfraContainer); err != nil {
 				glog.Warningf("Hairpin setup failed for pod %q: %v", format.Pod(pod), err)
 			}
 		}
@@ -1950,6 +2500,26 @@ func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubec
 		pod.Status.PodIP = dm.determineContainerIP(pod.Name, pod.Namespace, podInfraContainer)
 	}
 
+	if containerChanges.InitFailed {
+		glog.V(4).Infof("Not starting app containers for pod %q: an init container has failed", format.Pod(pod))
+		return
+	}
+
+	// Run init containers one at a time, in order, before any app container starts.
+	if next := containerChanges.NextInitContainerToStart; next != nil {
+		dm.pruneInitContainersBeforeStart(pod, podStatus, containerChanges.InitContainersToKeep)
+
+		startContainerResult := kubecontainer.NewSyncResult(kubecontainer.StartContainer, next.Name)
+		result.AddSyncResult(startContainerResult)
+		if err := dm.tryContainerStart(pod, next, podStatus, podInfraContainerID, pullSecrets, backOff, startContainerResult, containerChanges.StartInfraContainer, true); err != nil {
+			glog.Errorf("Error running pod %q init container %q: %v", format.Pod(pod), next.Name, err)
+			return
+		}
+		// Only start one init container per sync; the next sync will see its finished status
+		// and either move on to the following init container or start the app containers.
+		return
+	}
+
 	// Start everything
 	for idx := range containerChanges.ContainersToStart {
 		container := &pod.Spec.Containers[idx]
@@ -1958,57 +2528,114 @@ func (dm *DockerManager) SyncPod(pod *api.Pod, _ api.PodStatus, podStatus *kubec
 
 		// containerChanges.StartInfraContainer causes the containers to be restarted for config reasons
 		// ignore backoff
-		if !containerChanges.StartInfraContainer {
-			isInBackOff, err, msg := dm.doBackOff(pod, container, podStatus, backOff)
-			if isInBackOff {
-				startContainerResult.Fail(err, msg)
-				glog.V(4).Infof("Backing Off restarting container %+v in pod %v", container, format.Pod(pod))
-				continue
-			}
+		dm.tryContainerStart(pod, container, podStatus, podInfraContainerID, pullSecrets, backOff, startContainerResult, containerChanges.StartInfraContainer, false)
+	}
+	return
+}
+
+// tryContainerStart pulls the image for, verifies, and starts container, honoring backOff unless
+// ignoreBackOff is set (used when the pod infra container was just recreated, since everything
+// behind it needs to restart regardless of backoff state). If waitForCompletion is true, it blocks
+// until the container reaches a terminal state before returning; this is used for init containers,
+// which must finish before the next one (or the app containers) can start.
+func (dm *DockerManager) tryContainerStart(pod *api.Pod, container *api.Container, podStatus *kubecontainer.PodStatus, podInfraContainerID kubecontainer.DockerID, pullSecrets []api.Secret, backOff *util.Backoff, syncResult *kubecontainer.SyncResult, ignoreBackOff, waitForCompletion bool) error {
+	if !ignoreBackOff {
+		isInBackOff, err, msg := dm.doBackOff(pod, container, podStatus, backOff)
+		if isInBackOff {
+			syncResult.Fail(err, msg)
+			glog.V(4).Infof("Backing Off restarting container %+v in pod %v", container, format.Pod(pod))
+			return err
 		}
-		glog.V(4).Infof("Creating container %+v in pod %v", container, format.Pod(pod))
-		err, msg := dm.imagePuller.PullImage(pod, container, pullSecrets)
+	}
+	glog.V(4).Infof("Creating container %+v in pod %v", container, format.Pod(pod))
+	err, msg := dm.imagePuller.PullImage(pod, container, pullSecrets)
+	if err != nil {
+		syncResult.Fail(err, msg)
+		dm.updateReasonCache(pod, container, err.Error(), errors.New(msg))
+		return err
+	}
+
+	if container.SecurityContext != nil && container.SecurityContext.RunAsNonRoot != nil && *container.SecurityContext.RunAsNonRoot {
+		err := dm.verifyNonRoot(container)
+		dm.updateReasonCache(pod, container, kubecontainer.ErrVerifyNonRoot.Error(), err)
 		if err != nil {
-			startContainerResult.Fail(err, msg)
-			dm.updateReasonCache(pod, container, err.Error(), errors.New(msg))
-			continue
+			syncResult.Fail(kubecontainer.ErrVerifyNonRoot, err.Error())
+			glog.Errorf("Error running pod %q container %q: %v", format.Pod(pod), container.Name, err)
+			return err
+		}
+	}
+	// For a new container, the RestartCount should be 0. If the dead container we'd normally
+	// read it from has already been garbage collected, fall back to the checkpoint so the
+	// count doesn't silently reset.
+	restartCount := 0
+	containerStatus := podStatus.FindContainerStatusByName(container.Name)
+	if containerStatus != nil {
+		restartCount = containerStatus.RestartCount + 1
+	} else if dm.podCheckpoints != nil {
+		if rec, ok := dm.podCheckpoints.Get(pod.UID, container.Name); ok {
+			restartCount = rec.RestartCount + 1
 		}
+	}
 
-		if container.SecurityContext != nil && container.SecurityContext.RunAsNonRoot != nil && *container.SecurityContext.RunAsNonRoot {
-			err := dm.verifyNonRoot(container)
-			dm.updateReasonCache(pod, container, kubecontainer.ErrVerifyNonRoot.Error(), err)
-			if err != nil {
-				startContainerResult.Fail(kubecontainer.ErrVerifyNonRoot, err.Error())
-				glog.Errorf("Error running pod %q container %q: %v", format.Pod(pod), container.Name, err)
-				continue
-			}
+	// Persist the incremented restart count before starting the container, not after, so a
+	// kubelet crash between the two never under-counts a restart that already happened.
+	if dm.podCheckpoints != nil {
+		dockerName := KubeletContainerName{
+			PodFullName:   kubecontainer.GetPodFullName(pod),
+			PodUID:        pod.UID,
+			ContainerName: container.Name,
 		}
-		// For a new container, the RestartCount should be 0
-		restartCount := 0
-		containerStatus := podStatus.FindContainerStatusByName(container.Name)
+		stableName, _ := BuildDockerName(dockerName, container)
+		rec := podCheckpointRecord{RestartCount: restartCount, StableName: stableName}
 		if containerStatus != nil {
-			restartCount = containerStatus.RestartCount + 1
+			rec.FinishedAt = unversioned.NewTime(containerStatus.FinishedAt)
 		}
+		if err := dm.podCheckpoints.Write(pod.UID, container.Name, rec); err != nil {
+			glog.Errorf("Failed to checkpoint restart count for pod %q container %q: %v", format.Pod(pod), container.Name, err)
+		}
+	}
 
-		// TODO(dawnchen): Check RestartPolicy.DelaySeconds before restart a container
-		// Note: when configuring the pod's containers anything that can be configured by pointing
-		// to the namespace of the infra container should use namespaceMode.  This includes things like the net namespace
-		// and IPC namespace.  PID mode cannot point to another container right now.
-		// See createPodInfraContainer for infra container setup.
-		namespaceMode := fmt.Sprintf("container:%v", podInfraContainerID)
-		_, err = dm.runContainerInPod(pod, container, namespaceMode, namespaceMode, getPidMode(pod), restartCount)
-		dm.updateReasonCache(pod, container, kubecontainer.ErrRunContainer.Error(), err)
+	// TODO(dawnchen): Check RestartPolicy.DelaySeconds before restart a container
+	// Note: when configuring the pod's containers anything that can be configured by pointing
+	// to the namespace of the infra container should use namespaceMode.  This includes things like the net namespace
+	// and IPC namespace.  PID mode cannot point to another container right now.
+	// See createPodInfraContainer for infra container setup.
+	namespaceMode := fmt.Sprintf("container:%v", podInfraContainerID)
+	id, err := dm.runContainerInPod(pod, container, namespaceMode, namespaceMode, getPidMode(pod), restartCount)
+	dm.updateReasonCache(pod, container, kubecontainer.ErrRunContainer.Error(), err)
+	if err != nil {
+		syncResult.Fail(kubecontainer.ErrRunContainer, err.Error())
+		// TODO(bburns) : Perhaps blacklist a container after N failures?
+		glog.Errorf("Error running pod %q container %q: %v", format.Pod(pod), container.Name, err)
+		return err
+	}
+	// Successfully started the container; clear the entry in the failure
+	// reason cache.
+	dm.clearReasonCache(pod, container)
+
+	if !waitForCompletion {
+		return nil
+	}
+	return dm.waitForContainerTerminated(id)
+}
+
+// initContainerPollInterval is how often waitForContainerTerminated polls Docker while waiting
+// for an init container to finish.
+const initContainerPollInterval = 1 * time.Second
+
+// waitForContainerTerminated blocks until the container identified by id is no longer running, so
+// callers starting init containers can observe whether they succeeded before moving on.
+func (dm *DockerManager) waitForContainerTerminated(id kubecontainer.ContainerID) error {
+	for {
+		inspect, err := dm.client.InspectContainer(id.ID)
 		if err != nil {
-			startContainerResult.Fail(kubecontainer.ErrRunContainer, err.Error())
-			// TODO(bburns) : Perhaps blacklist a container after N failures?
-			glog.Errorf("Error running pod %q container %q: %v", format.Pod(pod), container.Name, err)
-			continue
+			return err
+		}
+		if !inspect.State.Running {
+			return nil
 		}
-		// Successfully started the container; clear the entry in the failure
-		// reason cache.
-		dm.clearReasonCache(pod, container)
+		time.Sleep(initContainerPollInterval)
 	}
-	return
 }
 
 // verifyNonRoot returns an error if the container or image will run as the root user.
@@ -2048,15 +2675,104 @@ func (dm *DockerManager) isImageRoot(image string) (bool, error) {
 	if user == "" {
 		return true, nil
 	}
-	// do not allow non-numeric user directives
-	uid, err := strconv.Atoi(user)
+	// user is numeric, check for 0
+	if uid, err := strconv.Atoi(user); err == nil {
+		return uid == 0, nil
+	}
+	// USER names a passwd entry rather than a raw uid (the standard "USER nobody"/"USER app"
+	// Dockerfile idiom); resolve it against the image's own /etc/passwd instead of rejecting
+	// it outright.
+	uid, err := dm.resolveImageUser(img.ID, user)
 	if err != nil {
-		return false, fmt.Errorf("non-numeric user (%s) is not allowed", user)
+		return false, fmt.Errorf("can't resolve non-numeric user (%s): %v", user, err)
 	}
-	// user is numeric, check for 0
 	return uid == 0, nil
 }
 
+// resolveImageUser resolves a non-numeric USER directive to a uid by reading the matching
+// entry out of the image's own /etc/passwd. Results are cached per (image ID, user); see
+// imageUserCache.
+func (dm *DockerManager) resolveImageUser(imageID, user string) (int, error) {
+	if uid, ok := dm.imageUserCache.Get(imageID, user); ok {
+		return uid, nil
+	}
+
+	uid, err := dm.lookupPasswdUid(imageID, user)
+	if err != nil {
+		return 0, err
+	}
+
+	dm.imageUserCache.Add(imageID, user, uid)
+	return uid, nil
+}
+
+// lookupPasswdUid creates a throwaway container from imageID (never started), copies /etc/passwd
+// out of its filesystem, and returns the uid of the entry named user.
+func (dm *DockerManager) lookupPasswdUid(imageID, user string) (int, error) {
+	container, err := dm.client.CreateContainer(docker.CreateContainerOptions{
+		Config: &docker.Config{Image: imageID},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create container to resolve user %q: %v", user, err)
+	}
+	defer func() {
+		if err := dm.client.RemoveContainer(docker.RemoveContainerOptions{ID: container.ID, Force: true}); err != nil {
+			glog.Warningf("Failed to remove throwaway container %q used to resolve user %q: %v", container.ID, user, err)
+		}
+	}()
+
+	var passwdTar bytes.Buffer
+	if err := dm.client.CopyFromContainer(docker.CopyFromContainerOptions{
+		Container:    container.ID,
+		Resource:     "etc/passwd",
+		OutputStream: &passwdTar,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to copy /etc/passwd from image: %v", err)
+	}
+
+	passwd, err := readTarFile(&passwdTar, "passwd")
+	if err != nil {
+		return 0, err
+	}
+	return uidFromPasswd(passwd, user)
+}
+
+// readTarFile returns the contents of the first entry in the tar stream r whose base name is
+// name. CopyFromContainer streams its result as a tar archive even for a single file.
+func readTarFile(r io.Reader, name string) ([]byte, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path.Base(hdr.Name) == name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+	return nil, fmt.Errorf("%s not found in tar stream", name)
+}
+
+// uidFromPasswd scans the contents of a passwd(5) file for the entry named user and returns
+// its uid.
+func uidFromPasswd(passwd []byte, user string) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(passwd))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 4)
+		if len(fields) < 3 || fields[0] != user {
+			continue
+		}
+		return strconv.Atoi(fields[2])
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no passwd entry for user %q", user)
+}
+
 // getUidFromUser splits the uid out of a uid:gid string.
 func getUidFromUser(id string) string {
 	if id == "" {
@@ -2141,13 +2857,11 @@ func (dm *DockerManager) GetPodStatus(uid types.UID, name, namespace string) (*k
 	// it to get the new restart count, and then add a label with the new restart count on
 	// the newly started container.
 	// However, there are some limitations of this method:
-	//	1. When all dead containers were garbage collected, the container status could
-	//	not get the historical value and would be *inaccurate*. Fortunately, the chance
-	//	is really slim.
-	//	2. When working with old version containers which have no restart count label,
+	//	1. When working with old version containers which have no restart count label,
 	//	we can only assume their restart count is 0.
-	// Anyhow, we only promised "best-effort" restart count reporting, we can just ignore
-	// these limitations now.
+	// The case where all dead containers were garbage collected used to make the restart
+	// count go stale; that's now covered below by falling back to dm.podCheckpoints, which
+	// tryContainerStart keeps up to date independently of any docker label.
 	var containerStatuses []*kubecontainer.ContainerStatus
 	// We have added labels like pod name and pod namespace, it seems that we can do filtered list here.
 	// However, there may be some old containers without these labels, so at least now we can't do that.
@@ -2161,6 +2875,7 @@ func (dm *DockerManager) GetPodStatus(uid types.UID, name, namespace string) (*k
 	// the statuses. We assume docker returns a list of containers sorted in
 	// reverse by time.
 	// TODO: optimization: set maximum number of containers per container name to examine.
+	seenContainers := make(map[string]bool)
 	for _, c := range containers {
 		if len(c.Names) == 0 {
 			continue
@@ -2178,11 +2893,57 @@ func (dm *DockerManager) GetPodStatus(uid types.UID, name, namespace string) (*k
 			return podStatus, err
 		}
 		containerStatuses = append(containerStatuses, result)
+		seenContainers[dockerName.ContainerName] = true
 		if ip != "" {
 			podStatus.IP = ip
 		}
 	}
 
+	// Any container name checkpointed for this pod but not seen above has had every one of its
+	// dead docker instances garbage collected; synthesize a status from the checkpoint instead
+	// of silently reporting restart count 0 for it.
+	if dm.podCheckpoints != nil {
+		for containerName, rec := range dm.podCheckpoints.List(uid) {
+			if seenContainers[containerName] {
+				continue
+			}
+			containerStatuses = append(containerStatuses, &kubecontainer.ContainerStatus{
+				Name:         containerName,
+				State:        kubecontainer.ContainerStateExited,
+				RestartCount: rec.RestartCount,
+				FinishedAt:   rec.FinishedAt.Time,
+			})
+		}
+	}
+
 	podStatus.ContainerStatuses = containerStatuses
 	return podStatus, nil
 }
+
+// StartReasonCacheGC starts a background sweep, at the given period, which
+// prunes reasonCache (and its on-disk journal) of entries for pods whose
+// UIDs are no longer present in podManager. Without this the persisted
+// journal would grow unboundedly as pods come and go across the life of
+// the node.
+func (dm *DockerManager) StartReasonCacheGC(podManager pod.Manager, period time.Duration) {
+	if dm.reasonCache.journal == nil {
+		return
+	}
+	go wait.Forever(func() {
+		dm.reasonCache.journal.pruneOrphans(&dm.reasonCache, podManager)
+	}, period)
+}
+
+// StartCheckpointGC starts a background sweep, at the given period, which prunes
+// dm.podCheckpoints of entries for pods podManager no longer knows about. The first sweep runs
+// immediately, which doubles as kubelet-restart recovery: it reconciles whatever checkpoints
+// survived the restart against podManager's freshly-populated view of the pods that are
+// actually still on this node before GetPodStatus or tryContainerStart ever consult them.
+func (dm *DockerManager) StartCheckpointGC(podManager pod.Manager, period time.Duration) {
+	if dm.podCheckpoints == nil {
+		return
+	}
+	go wait.Forever(func() {
+		dm.podCheckpoints.Reconcile(podManager)
+	}, period)
+}
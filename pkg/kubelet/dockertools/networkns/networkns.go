@@ -0,0 +1,121 @@
+// +build linux
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package networkns enters a container's network namespace directly from
+// Go via setns(2), replacing the nsenter+socat/awk shell-outs
+// DockerManager.PortForward and GetContainerIP previously depended on.
+package networkns
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// WithNetNS runs fn with the calling goroutine's OS thread moved into the
+// network namespace of the process identified by pid, restoring the
+// original namespace before returning. The goroutine's thread is locked
+// for the duration since namespace changes are per-thread, not per
+// process.
+func WithNetNS(pid int, fn func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	targetNS, err := os.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		return fmt.Errorf("failed to open network namespace of pid %d: %v", pid, err)
+	}
+	defer targetNS.Close()
+
+	currentNS, err := os.Open("/proc/self/ns/net")
+	if err != nil {
+		return fmt.Errorf("failed to open the current network namespace: %v", err)
+	}
+	defer currentNS.Close()
+
+	if err := setns(targetNS.Fd()); err != nil {
+		return fmt.Errorf("failed to enter network namespace of pid %d: %v", pid, err)
+	}
+	defer setns(currentNS.Fd())
+
+	return fn()
+}
+
+// setns wraps the setns(2) syscall for CLONE_NEWNET namespaces.
+func setns(fd uintptr) error {
+	const cloneNewNet = 0x40000000
+	if _, _, errno := syscall.Syscall(syscall.SYS_SETNS, fd, uintptr(cloneNewNet), 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// PortForward dials 127.0.0.1:port from inside the network namespace of
+// pid and copies data bidirectionally between the dialed connection and
+// stream, replacing the nsenter+socat pipeline.
+func PortForward(pid int, port uint16, stream io.ReadWriteCloser) error {
+	var conn *net.TCPConn
+	err := WithNetNS(pid, func() error {
+		addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: int(port)}
+		c, dialErr := net.DialTCP("tcp4", nil, addr)
+		if dialErr != nil {
+			return dialErr
+		}
+		conn = c
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dial port %d in network namespace of pid %d: %v", port, pid, err)
+	}
+	defer conn.Close()
+
+	errCh := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(conn, stream)
+		errCh <- err
+	}()
+	go func() {
+		_, err := io.Copy(stream, conn)
+		errCh <- err
+	}()
+	// The forward session ends when either direction hits EOF or an
+	// error; the caller (stream) owns closing on their side.
+	return <-errCh
+}
+
+// InterfaceAddrs returns the addresses assigned to ifaceName inside the
+// network namespace of pid, replacing the previous
+// `nsenter ... ip addr show | awk` pipeline.
+func InterfaceAddrs(pid int, ifaceName string) ([]net.Addr, error) {
+	var addrs []net.Addr
+	err := WithNetNS(pid, func() error {
+		iface, err := net.InterfaceByName(ifaceName)
+		if err != nil {
+			return err
+		}
+		addrs, err = iface.Addrs()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses for %q in network namespace of pid %d: %v", ifaceName, pid, err)
+	}
+	return addrs, nil
+}
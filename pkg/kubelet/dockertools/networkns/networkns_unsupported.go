@@ -0,0 +1,43 @@
+// +build !linux
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkns
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+// WithNetNS is not supported on platforms without setns(2) / network
+// namespaces.
+func WithNetNS(pid int, fn func() error) error {
+	return fmt.Errorf("network namespaces are not supported on this platform")
+}
+
+// PortForward is not supported on platforms without setns(2) / network
+// namespaces.
+func PortForward(pid int, port uint16, stream io.ReadWriteCloser) error {
+	return fmt.Errorf("network namespaces are not supported on this platform")
+}
+
+// InterfaceAddrs is not supported on platforms without setns(2) / network
+// namespaces.
+func InterfaceAddrs(pid int, ifaceName string) ([]net.Addr, error) {
+	return nil, fmt.Errorf("network namespaces are not supported on this platform")
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+// podmanRuntimeAdapter drives containers through podman's fork/exec,
+// daemonless model instead of talking to a long-running docker daemon
+// socket. It is selected with --container-runtime=podman.
+//
+// Rootless operation is supported: when uidMappings/gidMappings are
+// non-empty, every `podman create` is given a `--uidmap`/`--gidmap` pair so the
+// container runs inside a user namespace rather than requiring a
+// root-owned daemon, letting kubelets on multi-tenant nodes run workloads
+// without elevated privileges.
+type podmanRuntimeAdapter struct {
+	// Path to the podman binary, resolved once at construction time.
+	podmanPath string
+	// Rootless uid/gid map entries, e.g. "0:100000:65536". Empty means
+	// run with the caller's ambient identity (typically root).
+	uidMappings []string
+	gidMappings []string
+}
+
+// NewPodmanRuntimeAdapter returns a ContainerRuntimeAdapter backed by the
+// podman CLI. uidMappings/gidMappings, when non-empty, enable rootless
+// execution by passing --uidmap/--gidmap to every `podman create`.
+func NewPodmanRuntimeAdapter(uidMappings, gidMappings []string) (ContainerRuntimeAdapter, error) {
+	podmanPath, err := exec.LookPath("podman")
+	if err != nil {
+		return nil, fmt.Errorf("podman runtime requested but podman binary not found: %v", err)
+	}
+	return &podmanRuntimeAdapter{
+		podmanPath:  podmanPath,
+		uidMappings: uidMappings,
+		gidMappings: gidMappings,
+	}, nil
+}
+
+func (p *podmanRuntimeAdapter) Name() ContainerRuntimeAdapterName {
+	return RuntimeAdapterPodman
+}
+
+func (p *podmanRuntimeAdapter) run(args ...string) (string, error) {
+	glog.V(4).Infof("podman %s", strings.Join(args, " "))
+	out, err := exec.Command(p.podmanPath, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("podman %s failed: %v: %s", strings.Join(args, " "), err, string(out))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// namespaceModeArg translates the netMode/ipcMode/utsMode/pidMode strings
+// DockerManager.runContainer already computes (e.g. "host",
+// "container:<id>", or "") into the equivalent `podman run` flag value.
+func namespaceModeArg(mode string) string {
+	switch {
+	case mode == "":
+		return ""
+	case mode == namespaceModeHost:
+		return "host"
+	case strings.HasPrefix(mode, "container:"):
+		return mode
+	default:
+		return mode
+	}
+}
+
+// translatePortArgs turns kubecontainer.PortMapping entries -- the same
+// input makePortsAndBindings consumes for the docker backend -- into
+// `podman run -p` arguments.
+func translatePortArgs(portMappings []kubecontainer.PortMapping) []string {
+	var args []string
+	for _, port := range portMappings {
+		if port.HostPort == 0 {
+			continue
+		}
+		proto := "tcp"
+		if strings.EqualFold(string(port.Protocol), "udp") {
+			proto = "udp"
+		}
+		spec := fmt.Sprintf("%d:%d/%s", port.HostPort, port.ContainerPort, proto)
+		if port.HostIP != "" {
+			spec = fmt.Sprintf("%s:%d:%d/%s", port.HostIP, port.HostPort, port.ContainerPort, proto)
+		}
+		args = append(args, "-p", spec)
+	}
+	return args
+}
+
+// translateMountArgs turns kubecontainer.Mount entries -- the same input
+// makeMountBindings consumes for the docker backend -- into `podman run -v`
+// arguments, preserving the :ro and :Z/,Z suffixes and resolving SubPath
+// the same way the docker backend does.
+func translateMountArgs(mounts []kubecontainer.Mount, podHasSELinuxLabel bool) ([]string, error) {
+	var args []string
+	for _, m := range mounts {
+		hostPath := m.HostPath
+		if m.SubPath != "" {
+			resolved, err := resolveSubPath(m.HostPath, m.SubPath, m.SubPathDir)
+			if err != nil {
+				return nil, fmt.Errorf("error resolving subpath %q of %q: %v", m.SubPath, m.HostPath, err)
+			}
+			hostPath = resolved
+		}
+		spec := fmt.Sprintf("%s:%s", hostPath, m.ContainerPath)
+		if m.ReadOnly {
+			spec += ":ro"
+		}
+		if m.SELinuxRelabel && podHasSELinuxLabel {
+			if m.ReadOnly {
+				spec += ",Z"
+			} else {
+				spec += ":Z"
+			}
+		}
+		args = append(args, "-v", spec)
+	}
+	return args, nil
+}
+
+func (p *podmanRuntimeAdapter) CreateContainer(spec *RunContainerSpec) (string, error) {
+	args := []string{"create", "--name", spec.Name}
+
+	if spec.Hostname != "" {
+		args = append(args, "--hostname", spec.Hostname)
+	}
+	for _, e := range spec.Env {
+		args = append(args, "-e", e)
+	}
+	for k, v := range spec.Labels {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", k, v))
+	}
+	if spec.WorkingDir != "" {
+		args = append(args, "-w", spec.WorkingDir)
+	}
+	if spec.MemoryLimit > 0 {
+		args = append(args, "--memory", strconv.FormatInt(spec.MemoryLimit, 10))
+	}
+	if spec.CPUShares > 0 {
+		args = append(args, "--cpu-shares", strconv.FormatInt(spec.CPUShares, 10))
+	}
+	if spec.CPUQuota > 0 {
+		args = append(args, "--cpu-quota", strconv.FormatInt(spec.CPUQuota, 10))
+	}
+	if spec.CPUPeriod > 0 {
+		args = append(args, "--cpu-period", strconv.FormatInt(spec.CPUPeriod, 10))
+	}
+	if spec.OpenStdin {
+		args = append(args, "-i")
+	}
+	if spec.Tty {
+		args = append(args, "-t")
+	}
+	if netArg := namespaceModeArg(spec.NetworkMode); netArg != "" {
+		args = append(args, "--network", netArg)
+	}
+	if ipcArg := namespaceModeArg(spec.IPCMode); ipcArg != "" {
+		args = append(args, "--ipc", ipcArg)
+	}
+	if utsArg := namespaceModeArg(spec.UTSMode); utsArg != "" {
+		args = append(args, "--uts", utsArg)
+	}
+	if pidArg := namespaceModeArg(spec.PIDMode); pidArg != "" {
+		args = append(args, "--pid", pidArg)
+	}
+	for _, d := range spec.DNS {
+		args = append(args, "--dns", d)
+	}
+	for _, s := range spec.DNSSearch {
+		args = append(args, "--dns-search", s)
+	}
+	if spec.CgroupParent != "" {
+		args = append(args, "--cgroup-parent", spec.CgroupParent)
+	}
+	args = append(args, translatePortArgs(spec.PortMappings)...)
+	mountArgs, err := translateMountArgs(spec.Mounts, spec.SELinuxLabel)
+	if err != nil {
+		return "", err
+	}
+	args = append(args, mountArgs...)
+
+	// Rootless execution: run the container in a user namespace with the
+	// configured uid/gid maps instead of against a root-owned daemon.
+	for _, m := range p.uidMappings {
+		args = append(args, "--uidmap", m)
+	}
+	for _, m := range p.gidMappings {
+		args = append(args, "--gidmap", m)
+	}
+
+	if len(spec.Entrypoint) > 0 {
+		// Pass as a JSON array so podman splits it back into exactly
+		// spec.Entrypoint's argv, instead of re-splitting a joined string
+		// on whitespace and mangling multi-word entrypoints.
+		entrypoint, err := json.Marshal(spec.Entrypoint)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal entrypoint %v: %v", spec.Entrypoint, err)
+		}
+		args = append(args, "--entrypoint", string(entrypoint))
+	}
+	args = append(args, spec.Image)
+	args = append(args, spec.Cmd...)
+
+	return p.run(args...)
+}
+
+func (p *podmanRuntimeAdapter) StartContainer(id string) error {
+	_, err := p.run("start", id)
+	return err
+}
+
+func (p *podmanRuntimeAdapter) StopContainer(id string, gracePeriod uint) error {
+	_, err := p.run("stop", "-t", strconv.FormatUint(uint64(gracePeriod), 10), id)
+	return err
+}
+
+// podmanInspectState mirrors the subset of `podman inspect`'s State object
+// that DockerManager.inspectContainer needs in order to fill in a
+// kubecontainer.ContainerStatus the same way it does for docker.
+type podmanInspectState struct {
+	Running    bool
+	OOMKilled  bool
+	ExitCode   int
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Pid        int
+}
+
+type podmanInspectResult struct {
+	ID      string
+	Created time.Time
+	Image   string
+	Name    string
+	State   podmanInspectState
+	Mounts  []struct {
+		Destination string
+		Source      string
+	}
+}
+
+func (p *podmanRuntimeAdapter) InspectContainer(id string) (*kubecontainer.ContainerStatus, error) {
+	out, err := p.run("inspect", id)
+	if err != nil {
+		return nil, err
+	}
+	var results []podmanInspectResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		return nil, fmt.Errorf("failed to parse podman inspect output for %q: %v", id, err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("podman inspect %q returned no results", id)
+	}
+	r := results[0]
+
+	status := &kubecontainer.ContainerStatus{
+		ID:        kubecontainer.ContainerID{Type: string(RuntimeAdapterPodman), ID: r.ID},
+		Image:     r.Image,
+		ImageID:   r.Image,
+		ExitCode:  r.State.ExitCode,
+		CreatedAt: r.Created,
+	}
+
+	if r.State.Running {
+		status.State = kubecontainer.ContainerStateRunning
+		status.StartedAt = r.State.StartedAt
+		return status, nil
+	}
+
+	if !r.State.FinishedAt.IsZero() || r.State.ExitCode != 0 {
+		reason := ""
+		switch {
+		case r.State.OOMKilled:
+			reason = "OOMKilled"
+		case r.State.ExitCode == 0:
+			reason = "Completed"
+		case !r.State.FinishedAt.IsZero():
+			reason = "Error"
+		default:
+			reason = ErrContainerCannotRun.Error()
+		}
+		status.State = kubecontainer.ContainerStateExited
+		status.Reason = reason
+		status.Message = r.State.Error
+		status.StartedAt = r.State.StartedAt
+		status.FinishedAt = r.State.FinishedAt
+	} else {
+		status.State = kubecontainer.ContainerStateUnknown
+	}
+	return status, nil
+}
+
+func (p *podmanRuntimeAdapter) Logs(id string, opts *api.PodLogOptions, stdout, stderr io.Writer) error {
+	args := []string{"logs"}
+	if opts != nil && opts.Follow {
+		args = append(args, "-f")
+	}
+	if opts != nil && opts.TailLines != nil {
+		args = append(args, "--tail", strconv.FormatInt(*opts.TailLines, 10))
+	}
+	args = append(args, id)
+
+	cmd := exec.Command(p.podmanPath, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func (p *podmanRuntimeAdapter) Exec(id string, cmd []string, stdin io.Reader, stdout, stderr io.Writer, tty bool) error {
+	args := []string{"exec", "-i"}
+	if tty {
+		args = append(args, "-t")
+	}
+	args = append(args, id)
+	args = append(args, cmd...)
+
+	c := exec.Command(p.podmanPath, args...)
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return c.Run()
+}
+
+func (p *podmanRuntimeAdapter) PullImage(image string, pullSecrets []api.Secret) error {
+	// pullSecrets is not used: podman authenticates the pull with whatever
+	// its own containers-auth.json credential store (shared with
+	// skopeo/buildah) already holds.
+	_, err := p.run("pull", image)
+	return err
+}
+
+func (p *podmanRuntimeAdapter) ContainerPID(id string) (int, error) {
+	status, err := p.InspectContainer(id)
+	if err != nil {
+		return 0, err
+	}
+	out, err := p.run("inspect", "-f", "{{.State.Pid}}", id)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(out)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected pid value for container %q (state %v): %v", id, status.State, err)
+	}
+	return pid, nil
+}
+
+var _ ContainerRuntimeAdapter = &podmanRuntimeAdapter{}
@@ -0,0 +1,227 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/glog"
+	"k8s.io/kubernetes/pkg/kubelet/pod"
+	"k8s.io/kubernetes/pkg/types"
+)
+
+// reasonCacheJournalFile is the name of the append-only journal kept under
+// the kubelet root directory, keyed by <pod_UID>_<container_name>.
+const reasonCacheJournalFile = "reason_cache.journal"
+
+// reasonCacheRecord is one journal entry. Records with Remove set are tombstones
+// for reasonInfoCache.Remove; all other records are Add calls.
+type reasonCacheRecord struct {
+	Key     string `json:"key"`
+	Remove  bool   `json:"remove,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// reasonCacheJournal persists reasonInfoCache.Add/Remove calls to an
+// append-only, length-prefixed-with-CRC file so the cache can be hydrated
+// on kubelet restart instead of starting out empty (which previously meant
+// losing CrashLoopBackOff/ImagePullBackOff/ErrImageNeverPull reporting
+// across restarts).
+type reasonCacheJournal struct {
+	lock sync.Mutex
+	path string
+	file *os.File
+}
+
+// newReasonCacheJournal opens (creating if necessary) the journal file
+// under kubeletRoot.
+func newReasonCacheJournal(kubeletRoot string) (*reasonCacheJournal, error) {
+	path := filepath.Join(kubeletRoot, reasonCacheJournalFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &reasonCacheJournal{path: path, file: f}, nil
+}
+
+func writeRecord(w io.Writer, rec reasonCacheRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+	checksum := crc32.ChecksumIEEE(data)
+	var crcBuf [4]byte
+	binary.BigEndian.PutUint32(crcBuf[:], checksum)
+
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err = w.Write(crcBuf[:])
+	return err
+}
+
+// readRecords reads every well-formed record from the journal. A partial
+// trailing write (e.g. the kubelet was killed mid-append) is detected via
+// the length prefix or CRC mismatch and silently truncated, rather than
+// failing hydration outright.
+func readRecords(r *bufio.Reader) ([]reasonCacheRecord, error) {
+	var records []reasonCacheRecord
+	for {
+		lenBuf := make([]byte, 4)
+		if _, err := io.ReadFull(r, lenBuf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return records, err
+		}
+		length := binary.BigEndian.Uint32(lenBuf)
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			// Partial write at the tail; stop reading here.
+			break
+		}
+		crcBuf := make([]byte, 4)
+		if _, err := io.ReadFull(r, crcBuf); err != nil {
+			break
+		}
+		if binary.BigEndian.Uint32(crcBuf) != crc32.ChecksumIEEE(data) {
+			glog.Warningf("reasonCacheJournal: CRC mismatch reading %s, stopping replay", reasonCacheJournalFile)
+			break
+		}
+		var rec reasonCacheRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			glog.Warningf("reasonCacheJournal: skipping malformed record: %v", err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// Add appends an Add record for uid/name to the journal.
+func (j *reasonCacheJournal) Add(uid types.UID, name, reason, message string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	key := (&reasonInfoCache{}).composeKey(uid, name)
+	if err := writeRecord(j.file, reasonCacheRecord{Key: key, Reason: reason, Message: message}); err != nil {
+		glog.Errorf("reasonCacheJournal: failed to persist Add(%s): %v", key, err)
+	}
+}
+
+// Remove appends a tombstone record for uid/name to the journal.
+func (j *reasonCacheJournal) Remove(uid types.UID, name string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	key := (&reasonInfoCache{}).composeKey(uid, name)
+	if err := writeRecord(j.file, reasonCacheRecord{Key: key, Remove: true}); err != nil {
+		glog.Errorf("reasonCacheJournal: failed to persist Remove(%s): %v", key, err)
+	}
+}
+
+// Load replays the journal into cache, then compacts the file down to a
+// single record per live key so a kubelet that restarts often doesn't grow
+// the journal unboundedly from replaying the same key's history forever.
+func (j *reasonCacheJournal) Load(cache *reasonInfoCache) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	records, err := readRecords(bufio.NewReader(j.file))
+	if err != nil {
+		return err
+	}
+
+	live := make(map[string]reasonCacheRecord)
+	for _, rec := range records {
+		if rec.Remove {
+			delete(live, rec.Key)
+			continue
+		}
+		live[rec.Key] = rec
+	}
+	for key, rec := range live {
+		cache.addByKey(key, rec.Reason, rec.Message)
+	}
+	return j.compactLocked(live)
+}
+
+// compactLocked rewrites the journal to contain exactly one Add record per
+// key in live, discarding tombstones and superseded history.
+func (j *reasonCacheJournal) compactLocked(live map[string]reasonCacheRecord) error {
+	tmpPath := j.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for _, rec := range live {
+		if err := writeRecord(tmp, rec); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return err
+	}
+	j.file.Close()
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.file = f
+	return nil
+}
+
+// pruneOrphans removes journal entries for pod UIDs that podManager no
+// longer knows about, as part of the background sweep so the journal
+// doesn't grow unboundedly from pods that have long since been deleted.
+func (j *reasonCacheJournal) pruneOrphans(cache *reasonInfoCache, podManager pod.Manager) {
+	liveUIDs := make(map[types.UID]bool)
+	for _, p := range podManager.GetPods() {
+		liveUIDs[p.UID] = true
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	stale := cache.keysForUIDsNotIn(liveUIDs)
+	for _, key := range stale {
+		cache.removeByKey(key)
+		if err := writeRecord(j.file, reasonCacheRecord{Key: key, Remove: true}); err != nil {
+			glog.Errorf("reasonCacheJournal: failed to persist prune tombstone for %s: %v", key, err)
+		}
+	}
+	if len(stale) > 0 {
+		glog.V(4).Infof("reasonCacheJournal: pruned %d entries for pods no longer known to the podManager", len(stale))
+	}
+}
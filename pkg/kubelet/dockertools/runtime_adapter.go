@@ -0,0 +1,102 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockertools
+
+import (
+	"io"
+
+	"k8s.io/kubernetes/pkg/api"
+	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
+)
+
+// ContainerRuntimeAdapterName identifies a concrete ContainerRuntimeAdapter
+// implementation. It is the value accepted by the kubelet's
+// --container-runtime flag.
+type ContainerRuntimeAdapterName string
+
+const (
+	// RuntimeAdapterDocker drives containers through the docker daemon.
+	RuntimeAdapterDocker ContainerRuntimeAdapterName = "docker"
+	// RuntimeAdapterPodman drives containers through podman's daemonless
+	// fork/exec model.
+	RuntimeAdapterPodman ContainerRuntimeAdapterName = "podman"
+)
+
+// RunContainerSpec carries everything an adapter needs in order to create
+// and start a single container. It is the adapter-agnostic analogue of the
+// docker.CreateContainerOptions/docker.HostConfig pair built by runContainer.
+type RunContainerSpec struct {
+	Name          string
+	Image         string
+	Hostname      string
+	Env           []string
+	Entrypoint    []string
+	Cmd           []string
+	WorkingDir    string
+	Labels        map[string]string
+	OpenStdin     bool
+	StdinOnce     bool
+	Tty           bool
+	MemoryLimit   int64
+	CPUShares     int64
+	CPUQuota      int64
+	CPUPeriod     int64
+	PortMappings  []kubecontainer.PortMapping
+	Mounts        []kubecontainer.Mount
+	NetworkMode   string
+	IPCMode       string
+	UTSMode       string
+	PIDMode       string
+	DNS           []string
+	DNSSearch     []string
+	CgroupParent  string
+	SELinuxLabel  bool
+}
+
+// ContainerRuntimeAdapter is the narrow, runtime-agnostic surface that
+// DockerManager drives containers through. It intentionally only exposes
+// the operations DockerManager actually needs (create/start/stop/inspect/
+// logs/exec/pull/port-mapping) so that a backend which has nothing to do
+// with the docker daemon -- e.g. Podman's fork/exec model -- can implement
+// it without pulling in github.com/fsouza/go-dockerclient.
+type ContainerRuntimeAdapter interface {
+	// Name returns the backend name, e.g. "docker" or "podman".
+	Name() ContainerRuntimeAdapterName
+
+	// CreateContainer creates (but does not start) a container from spec
+	// and returns its runtime-assigned ID.
+	CreateContainer(spec *RunContainerSpec) (string, error)
+	// StartContainer starts a previously created container.
+	StartContainer(id string) error
+	// StopContainer stops a running container, allowing up to
+	// gracePeriod seconds before killing it.
+	StopContainer(id string, gracePeriod uint) error
+	// InspectContainer fills in kubecontainer.ContainerStatus from the
+	// runtime's inspect result: state, exit code, started/finished times,
+	// and an "OOMKilled" reason for OOM-killed containers. It does not read
+	// the container's termination message.
+	InspectContainer(id string) (*kubecontainer.ContainerStatus, error)
+	// Logs streams the container's logs to stdout/stderr per opts.
+	Logs(id string, opts *api.PodLogOptions, stdout, stderr io.Writer) error
+	// Exec runs cmd inside the running container id.
+	Exec(id string, cmd []string, stdin io.Reader, stdout, stderr io.Writer, tty bool) error
+	// PullImage pulls image, using pullSecrets for registry auth if needed.
+	PullImage(image string, pullSecrets []api.Secret) error
+	// ContainerPID returns the PID of the container's init process, for
+	// use by the network plugin and hairpin setup.
+	ContainerPID(id string) (int, error)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package seccomp resolves the seccomp profile a container should run
+// under from pod annotations, mirroring the annotation model CRI-O's
+// server/seccomp package implements.
+package seccomp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PodAnnotationKey is the pod-level default seccomp profile
+	// annotation, applied to every container that doesn't set its own.
+	PodAnnotationKey = "seccomp.security.alpha.kubernetes.io/pod"
+	// ContainerAnnotationKeyPrefix is prepended to a container name to
+	// form its per-container override annotation key.
+	ContainerAnnotationKeyPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+
+	// ProfileRuntimeDefault requests the container engine's compiled-in
+	// default profile.
+	ProfileRuntimeDefault = "runtime/default"
+	// ProfileDockerDefault is a deprecated alias for ProfileRuntimeDefault.
+	ProfileDockerDefault = "docker/default"
+	// ProfileNamePrefix must prefix any profile loaded from the
+	// seccomp root directory, e.g. "localhost/my-profile.json".
+	ProfileNamePrefix = "localhost/"
+	// ProfileUnconfined disables seccomp filtering entirely.
+	ProfileUnconfined = "unconfined"
+
+	// DefaultSeccompRoot is where "localhost/" profiles are read from
+	// when the kubelet doesn't override it.
+	DefaultSeccompRoot = "/var/lib/kubelet/seccomp"
+
+	// minSeccompAPIVersion is the first Docker API version (1.22, i.e.
+	// Docker 1.10) that understands the seccomp SecurityOpt.
+	minSeccompAPIVersion = "1.22"
+)
+
+// ContainerAnnotationKey returns the pod annotation key that carries the
+// seccomp profile override for the named container.
+func ContainerAnnotationKey(containerName string) string {
+	return ContainerAnnotationKeyPrefix + containerName
+}
+
+// ProfileName resolves the effective seccomp profile for a container: a
+// container-level annotation takes precedence over the pod-level default,
+// which in turn defaults to the runtime's own default profile.
+func ProfileName(annotations map[string]string, containerName string) string {
+	if profile, ok := annotations[ContainerAnnotationKey(containerName)]; ok && profile != "" {
+		return profile
+	}
+	if profile, ok := annotations[PodAnnotationKey]; ok && profile != "" {
+		return profile
+	}
+	return ProfileRuntimeDefault
+}
+
+// seccompProfile is the OCI-style schema localhost/ profiles must conform
+// to: a default action plus a list of syscall-specific overrides.
+type seccompProfile struct {
+	DefaultAction string           `json:"defaultAction"`
+	Syscalls      []seccompSyscall `json:"syscalls"`
+}
+
+type seccompSyscall struct {
+	Names  []string      `json:"names"`
+	Action string        `json:"action"`
+	Args   []interface{} `json:"args,omitempty"`
+}
+
+// validActions enumerates the seccomp actions the OCI runtime spec
+// defines; anything else is a malformed profile.
+var validActions = map[string]bool{
+	"SCMP_ACT_KILL":  true,
+	"SCMP_ACT_TRAP":  true,
+	"SCMP_ACT_ERRNO": true,
+	"SCMP_ACT_TRACE": true,
+	"SCMP_ACT_ALLOW": true,
+}
+
+// Resolver loads and validates "localhost/" seccomp profiles from a root
+// directory and resolves the well-known profile names into the
+// docker.HostConfig.SecurityOpt value the DockerManager runContainer path
+// should apply.
+type Resolver struct {
+	// rootDir is where "localhost/<path>" profiles are read from.
+	rootDir string
+}
+
+// NewResolver returns a Resolver rooted at rootDir. An empty rootDir
+// defaults to DefaultSeccompRoot.
+func NewResolver(rootDir string) *Resolver {
+	if rootDir == "" {
+		rootDir = DefaultSeccompRoot
+	}
+	return &Resolver{rootDir: rootDir}
+}
+
+// Resolve turns a profile name (as returned by ProfileName) into the
+// SecurityOpt value docker/podman expect, e.g. "seccomp=unconfined" or
+// "seccomp=<json>".
+func (r *Resolver) Resolve(profile string) (string, error) {
+	switch {
+	case profile == "" || profile == ProfileUnconfined:
+		return "seccomp=unconfined", nil
+	case profile == ProfileRuntimeDefault || profile == ProfileDockerDefault:
+		// The engine's compiled-in default is applied by omitting the
+		// seccomp SecurityOpt entirely.
+		return "", nil
+	case strings.HasPrefix(profile, ProfileNamePrefix):
+		name := strings.TrimPrefix(profile, ProfileNamePrefix)
+		if name == "" || strings.Contains(name, "..") {
+			return "", fmt.Errorf("invalid seccomp profile name %q", profile)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(r.rootDir, name))
+		if err != nil {
+			return "", fmt.Errorf("could not read seccomp profile %q: %v", profile, err)
+		}
+		if err := validate(data); err != nil {
+			return "", fmt.Errorf("invalid seccomp profile %q: %v", profile, err)
+		}
+		return "seccomp=" + string(data), nil
+	default:
+		return "", fmt.Errorf("unknown seccomp profile format %q", profile)
+	}
+}
+
+// validate checks data against the minimal OCI-style seccomp schema:
+// a recognized defaultAction, and every syscalls[] entry naming at least
+// one syscall with a recognized action.
+func validate(data []byte) error {
+	var profile seccompProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return fmt.Errorf("malformed JSON: %v", err)
+	}
+	if !validActions[profile.DefaultAction] {
+		return fmt.Errorf("unrecognized defaultAction %q", profile.DefaultAction)
+	}
+	for _, sc := range profile.Syscalls {
+		if len(sc.Names) == 0 {
+			return fmt.Errorf("syscalls entry has no names")
+		}
+		if !validActions[sc.Action] {
+			return fmt.Errorf("unrecognized action %q for syscalls %v", sc.Action, sc.Names)
+		}
+	}
+	return nil
+}
+
+// SupportsSeccomp reports whether a docker daemon at apiVersion is new
+// enough to understand the seccomp SecurityOpt (API >= 1.22, i.e. Docker
+// 1.10+). Callers should fall back to "unconfined" with a warning event
+// when this returns false, the same way exec support is gated by
+// nativeExecSupportExists().
+func SupportsSeccomp(compare func(other string) (int, error)) (bool, error) {
+	result, err := compare(minSeccompAPIVersion)
+	if err != nil {
+		return false, err
+	}
+	return result >= 0, nil
+}
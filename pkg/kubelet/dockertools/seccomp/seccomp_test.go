@@ -0,0 +1,103 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package seccomp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileNamePrecedence(t *testing.T) {
+	annotations := map[string]string{
+		PodAnnotationKey:              "localhost/pod-default.json",
+		ContainerAnnotationKey("foo"): "unconfined",
+	}
+	if got := ProfileName(annotations, "foo"); got != "unconfined" {
+		t.Errorf("expected container-level override to win, got %q", got)
+	}
+	if got := ProfileName(annotations, "bar"); got != "localhost/pod-default.json" {
+		t.Errorf("expected pod-level default for container without an override, got %q", got)
+	}
+	if got := ProfileName(nil, "baz"); got != ProfileRuntimeDefault {
+		t.Errorf("expected runtime default with no annotations, got %q", got)
+	}
+}
+
+func TestResolveWellKnownProfiles(t *testing.T) {
+	r := NewResolver("")
+	cases := map[string]string{
+		"":                    "seccomp=unconfined",
+		ProfileUnconfined:     "seccomp=unconfined",
+		ProfileRuntimeDefault: "",
+		ProfileDockerDefault:  "",
+	}
+	for profile, want := range cases {
+		got, err := r.Resolve(profile)
+		if err != nil {
+			t.Errorf("Resolve(%q) returned error: %v", profile, err)
+		}
+		if got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", profile, got, want)
+		}
+	}
+}
+
+func TestResolveLocalhostProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seccomp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	validProfile := `{"defaultAction":"SCMP_ACT_ERRNO","syscalls":[{"names":["accept"],"action":"SCMP_ACT_ALLOW"}]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "good.json"), []byte(validProfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	malformedProfile := `{"defaultAction":"SCMP_ACT_ERRNO","syscalls":[{"names":[],"action":"SCMP_ACT_ALLOW"}]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.json"), []byte(malformedProfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unknownAction := `{"defaultAction":"NOT_A_REAL_ACTION","syscalls":[]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad-action.json"), []byte(unknownAction), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResolver(dir)
+
+	got, err := r.Resolve("localhost/good.json")
+	if err != nil {
+		t.Fatalf("Resolve(good.json) returned error: %v", err)
+	}
+	if got != "seccomp="+validProfile {
+		t.Errorf("Resolve(good.json) = %q, want %q", got, "seccomp="+validProfile)
+	}
+
+	if _, err := r.Resolve("localhost/bad.json"); err == nil {
+		t.Error("expected an error for a syscalls entry with no names")
+	}
+	if _, err := r.Resolve("localhost/bad-action.json"); err == nil {
+		t.Error("expected an error for an unrecognized defaultAction")
+	}
+	if _, err := r.Resolve("localhost/missing.json"); err == nil {
+		t.Error("expected an error for a missing profile file")
+	}
+	if _, err := r.Resolve("localhost/../escape.json"); err == nil {
+		t.Error("expected an error for a profile name containing ..")
+	}
+}
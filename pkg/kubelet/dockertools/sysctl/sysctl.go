@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sysctl parses the pod annotations that request per-pod namespaced
+// sysctls, mirroring the annotation-driven model the apparmor and seccomp
+// packages use for other alpha security features.
+package sysctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// SysctlsAnnotationKey holds the comma-separated list of "safe" sysctls
+	// (ones that are namespaced and cannot affect other pods) a pod requests.
+	SysctlsAnnotationKey = "security.alpha.kubernetes.io/sysctls"
+	// UnsafeSysctlsAnnotationKey holds the comma-separated list of sysctls a
+	// pod requests that the cluster admin must explicitly allow via
+	// --experimental-allowed-unsafe-sysctls before they are honored.
+	UnsafeSysctlsAnnotationKey = "security.alpha.kubernetes.io/unsafe-sysctls"
+)
+
+// PodSysctls parses the safe and unsafe sysctl lists requested by annotations, returning
+// them as key/value maps. It returns an error if either list is malformed.
+func PodSysctls(annotations map[string]string) (safe, unsafe map[string]string, err error) {
+	safe, err = parseList(annotations[SysctlsAnnotationKey])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s annotation: %v", SysctlsAnnotationKey, err)
+	}
+	unsafe, err = parseList(annotations[UnsafeSysctlsAnnotationKey])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s annotation: %v", UnsafeSysctlsAnnotationKey, err)
+	}
+	return safe, unsafe, nil
+}
+
+// parseList parses a comma-separated "key=value,key=value" list into a map. An empty
+// string parses to an empty, non-nil map.
+func parseList(value string) (map[string]string, error) {
+	result := make(map[string]string)
+	if value == "" {
+		return result, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("malformed sysctl %q, expected key=value", item)
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result, nil
+}
+
+// IsAllowed reports whether name is permitted by the admin's allowlist of unsafe sysctls,
+// which may contain exact names (e.g. "kernel.msgmax") or "*"-suffixed prefixes
+// (e.g. "net.ipv4.*") matching any sysctl in that namespace.
+func IsAllowed(name string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if pattern == name {
+			return true
+		}
+		if strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
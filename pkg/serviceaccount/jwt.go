@@ -0,0 +1,397 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package serviceaccount provides support for JWT tokens that authenticate
+// Kubernetes ServiceAccounts.
+package serviceaccount
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/auth/user"
+)
+
+const (
+	ServiceAccountUsernamePrefix    = "system:serviceaccount:"
+	ServiceAccountUsernameSeparator = ":"
+
+	claimIssuer = "kubernetes/serviceaccount"
+)
+
+// jwtHeader is the JOSE header of a ServiceAccount token. kid names the
+// KeyProvider key that produced Signature, so the authenticator knows
+// which trusted public key to verify against.
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// jwtClaims is the claim set embedded in a ServiceAccount token.
+type jwtClaims struct {
+	Issuer             string   `json:"iss"`
+	Subject            string   `json:"sub"`
+	Namespace          string   `json:"kubernetes.io/serviceaccount/namespace"`
+	ServiceAccountName string   `json:"kubernetes.io/serviceaccount/service-account.name"`
+	ServiceAccountUID  string   `json:"kubernetes.io/serviceaccount/service-account.uid"`
+	SecretName         string   `json:"kubernetes.io/serviceaccount/secret.name,omitempty"`
+	Audience           []string `json:"aud,omitempty"`
+	ExpiresAt          int64    `json:"exp,omitempty"`
+
+	// BoundPod ties the token to a specific Pod: the token stops
+	// authenticating as soon as that Pod is deleted, even if it hasn't
+	// hit ExpiresAt yet.
+	BoundPod *BoundObjectReference `json:"kubernetes.io/serviceaccount/pod,omitempty"`
+	// BoundNode ties the token to a specific Node the same way BoundPod
+	// ties it to a Pod.
+	BoundNode *BoundObjectReference `json:"kubernetes.io/serviceaccount/node,omitempty"`
+}
+
+// BoundObjectReference identifies the Pod or Node a bound token is scoped
+// to.
+type BoundObjectReference struct {
+	Name string `json:"name"`
+	UID  string `json:"uid"`
+}
+
+// MakeUsername generates the username to use for the given service account.
+func MakeUsername(namespace, name string) string {
+	return ServiceAccountUsernamePrefix + namespace + ServiceAccountUsernameSeparator + name
+}
+
+// SplitUsername returns the namespace and name of the ServiceAccount
+// encoded by MakeUsername, or an error if username was not generated by it.
+func SplitUsername(username string) (namespace, name string, err error) {
+	if !strings.HasPrefix(username, ServiceAccountUsernamePrefix) {
+		return "", "", fmt.Errorf("username %q is not a service account username", username)
+	}
+	trimmed := strings.TrimPrefix(username, ServiceAccountUsernamePrefix)
+	parts := strings.Split(trimmed, ServiceAccountUsernameSeparator)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("username %q is not a valid service account username", username)
+	}
+	return parts[0], parts[1], nil
+}
+
+// MakeGroupNames returns the groups a ServiceAccount token's identity
+// belongs to: the cluster-wide group shared by every service account, plus
+// the group scoped to its own namespace.
+func MakeGroupNames(namespace string) []string {
+	return []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace}
+}
+
+// TokenGenerator generates a signed token for a ServiceAccount.
+type TokenGenerator interface {
+	// GenerateToken generates a token which will identify the given
+	// ServiceAccount. secret is the api.Secret the token will be stored
+	// in; its name is folded into the claims so the authenticator can
+	// cross-check token, secret, and service account UID all still line
+	// up. It also returns the ID of the key used to sign the token, which
+	// the caller should stamp onto the secret (e.g. under
+	// TokenSigningKeyIDAnnotation) so a later key rotation can tell
+	// whether this particular secret needs to be reissued.
+	GenerateToken(serviceAccount api.ServiceAccount, secret api.Secret) (token string, keyID string, err error)
+}
+
+// BoundTokenGenerator generates short-lived tokens for the TokenRequest
+// API: unlike TokenGenerator's tokens, they are never written into a
+// Secret, carry a caller-chosen audience and expiry instead of living
+// forever, and can be bound to a Pod or Node so that deleting the bound
+// object invalidates the token immediately.
+type BoundTokenGenerator interface {
+	// GenerateBoundToken mints a token for serviceAccount scoped to
+	// audiences and expiring after expirationSeconds (capped by the
+	// generator's configured maximum; a value <= 0 also gets the
+	// maximum). boundPod and/or boundNode, if non-nil, are folded into
+	// the token's claims so JWTTokenAuthenticator can reject the token
+	// once the referenced object no longer exists.
+	GenerateBoundToken(serviceAccount api.ServiceAccount, audiences []string, expirationSeconds int64, boundPod, boundNode *BoundObjectReference) (string, error)
+}
+
+// JWTTokenGenerator returns a TokenGenerator that signs tokens with the
+// active key from keyProvider, stamping its key ID into the "kid" header
+// so JWTTokenAuthenticator can select the matching verification key later.
+func JWTTokenGenerator(keyProvider KeyProvider) TokenGenerator {
+	return &jwtTokenGenerator{keyProvider: keyProvider}
+}
+
+// NewBoundTokenGenerator returns a BoundTokenGenerator that signs with
+// keyProvider's active key the same way JWTTokenGenerator does, clamping
+// every requested expiration to at most maxExpirationSeconds.
+func NewBoundTokenGenerator(keyProvider KeyProvider, maxExpirationSeconds int64) BoundTokenGenerator {
+	return &jwtTokenGenerator{keyProvider: keyProvider, maxExpirationSeconds: maxExpirationSeconds}
+}
+
+type jwtTokenGenerator struct {
+	keyProvider KeyProvider
+
+	// maxExpirationSeconds bounds GenerateBoundToken's expirationSeconds
+	// argument. Unused by the plain TokenGenerator path, whose tokens
+	// never expire.
+	maxExpirationSeconds int64
+}
+
+func (j *jwtTokenGenerator) GenerateToken(serviceAccount api.ServiceAccount, secret api.Secret) (string, string, error) {
+	claims := jwtClaims{
+		Issuer:             claimIssuer,
+		Subject:            MakeUsername(serviceAccount.Namespace, serviceAccount.Name),
+		Namespace:          serviceAccount.Namespace,
+		ServiceAccountName: serviceAccount.Name,
+		ServiceAccountUID:  string(serviceAccount.UID),
+		SecretName:         secret.Name,
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", "", err
+	}
+
+	keyID, signature, signingInput, err := j.sign(claimsJSON)
+	if err != nil {
+		return "", "", err
+	}
+
+	return signingInput + "." + base64URLEncode(signature), keyID, nil
+}
+
+// GenerateBoundToken implements BoundTokenGenerator.
+func (j *jwtTokenGenerator) GenerateBoundToken(serviceAccount api.ServiceAccount, audiences []string, expirationSeconds int64, boundPod, boundNode *BoundObjectReference) (string, error) {
+	if expirationSeconds <= 0 || expirationSeconds > j.maxExpirationSeconds {
+		expirationSeconds = j.maxExpirationSeconds
+	}
+	claims := jwtClaims{
+		Issuer:             claimIssuer,
+		Subject:            MakeUsername(serviceAccount.Namespace, serviceAccount.Name),
+		Namespace:          serviceAccount.Namespace,
+		ServiceAccountName: serviceAccount.Name,
+		ServiceAccountUID:  string(serviceAccount.UID),
+		Audience:           audiences,
+		ExpiresAt:          time.Now().Add(time.Duration(expirationSeconds) * time.Second).Unix(),
+		BoundPod:           boundPod,
+		BoundNode:          boundNode,
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	_, signature, signingInput, err := j.sign(claimsJSON)
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64URLEncode(signature), nil
+}
+
+// sign encodes claimsJSON into a header.claims signing input and signs it
+// with the KeyProvider's active key.
+func (j *jwtTokenGenerator) sign(claimsJSON []byte) (keyID, signature, signingInput string, err error) {
+	activeKeyID, err := j.keyProvider.ActiveKeyID()
+	if err != nil {
+		return "", "", "", err
+	}
+	header, err := json.Marshal(jwtHeader{Algorithm: "RS256", KeyID: activeKeyID})
+	if err != nil {
+		return "", "", "", err
+	}
+	signingInput = base64URLEncode(header) + "." + base64URLEncode(claimsJSON)
+
+	usedKeyID, sig, err := j.keyProvider.Sign([]byte(signingInput))
+	if err != nil {
+		return "", "", "", err
+	}
+	return usedKeyID, string(sig), signingInput, nil
+}
+
+// ServiceAccountTokenGetter defines functions to retrieve a named
+// ServiceAccount, Secret, Pod and Node.
+type ServiceAccountTokenGetter interface {
+	GetServiceAccount(namespace, name string) (*api.ServiceAccount, error)
+	GetSecret(namespace, name string) (*api.Secret, error)
+	// GetPod retrieves the named Pod, used to check whether a bound
+	// token's BoundPod still exists.
+	GetPod(namespace, name string) (*api.Pod, error)
+	// GetNode retrieves the named Node, used to check whether a bound
+	// token's BoundNode still exists.
+	GetNode(name string) (*api.Node, error)
+}
+
+// JWTTokenAuthenticator returns an authenticator.Token that verifies JWTs
+// using keyProvider's public keys, selecting the verification key by the
+// token's "kid" header so multiple active keys (during a key rollover)
+// all verify successfully. If lookup is true, the token, its issuing
+// secret, and the named ServiceAccount are all cross-checked via getter.
+// Regardless of lookup, a token bound to a Pod or Node is always
+// cross-checked against getter, since an unchecked bound token would never
+// actually stop authenticating once its bound object is deleted.
+func JWTTokenAuthenticator(keyProvider KeyProvider, lookup bool, getter ServiceAccountTokenGetter) *jwtTokenAuthenticator {
+	return &jwtTokenAuthenticator{keyProvider: keyProvider, lookup: lookup, getter: getter}
+}
+
+type jwtTokenAuthenticator struct {
+	keyProvider KeyProvider
+	lookup      bool
+	getter      ServiceAccountTokenGetter
+}
+
+// AuthenticateToken implements authenticator.Token. It is equivalent to
+// AuthenticateTokenWithAudiences with no expected audiences, i.e. it
+// accepts a token scoped to any audience (or none).
+func (j *jwtTokenAuthenticator) AuthenticateToken(rawToken string) (user.Info, bool, error) {
+	return j.AuthenticateTokenWithAudiences(rawToken, nil)
+}
+
+// AuthenticateTokenWithAudiences authenticates rawToken like
+// AuthenticateToken, additionally rejecting it if it carries an "aud"
+// claim and none of its audiences are in expectedAudiences. A token with
+// no "aud" claim authenticates regardless of expectedAudiences, since it
+// was never scoped to an audience to begin with.
+func (j *jwtTokenAuthenticator) AuthenticateTokenWithAudiences(rawToken string, expectedAudiences []string) (user.Info, bool, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, false, nil
+	}
+	headerJSON, err := base64URLDecode(parts[0])
+	if err != nil {
+		return nil, false, nil
+	}
+	claimsJSON, err := base64URLDecode(parts[1])
+	if err != nil {
+		return nil, false, nil
+	}
+	signature, err := base64URLDecode(parts[2])
+	if err != nil {
+		return nil, false, nil
+	}
+
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, false, nil
+	}
+
+	publicKeys, err := j.keyProvider.PublicKeys()
+	if err != nil {
+		return nil, false, err
+	}
+	publicKey, ok := publicKeys[header.KeyID]
+	if !ok {
+		// The kid isn't in the currently trusted set: either the token
+		// was forged, or it was signed by a key that has since been
+		// retired.
+		return nil, false, nil
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashSHA256([]byte(signingInput)), signature); err != nil {
+		return nil, false, nil
+	}
+
+	var claims jwtClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, false, nil
+	}
+	if claims.Issuer != claimIssuer || len(claims.Namespace) == 0 || len(claims.ServiceAccountName) == 0 {
+		return nil, false, nil
+	}
+	if len(claims.Audience) > 0 && !audiencesIntersect(claims.Audience, expectedAudiences) {
+		return nil, false, nil
+	}
+	if claims.ExpiresAt > 0 && time.Now().Unix() > claims.ExpiresAt {
+		return nil, false, nil
+	}
+	if claims.BoundPod != nil {
+		pod, err := j.getter.GetPod(claims.Namespace, claims.BoundPod.Name)
+		if err != nil {
+			return nil, false, nil
+		}
+		if string(pod.UID) != claims.BoundPod.UID {
+			return nil, false, nil
+		}
+	}
+	if claims.BoundNode != nil {
+		node, err := j.getter.GetNode(claims.BoundNode.Name)
+		if err != nil {
+			return nil, false, nil
+		}
+		if string(node.UID) != claims.BoundNode.UID {
+			return nil, false, nil
+		}
+	}
+
+	if j.lookup {
+		secret, err := j.getter.GetSecret(claims.Namespace, claims.SecretName)
+		if err != nil {
+			return nil, false, nil
+		}
+		if string(secret.Data[api.ServiceAccountTokenKey]) != rawToken {
+			return nil, false, nil
+		}
+		serviceAccount, err := j.getter.GetServiceAccount(claims.Namespace, claims.ServiceAccountName)
+		if err != nil {
+			return nil, false, nil
+		}
+		if string(serviceAccount.UID) != claims.ServiceAccountUID {
+			return nil, false, nil
+		}
+	}
+
+	return &user.DefaultInfo{
+		Name:   MakeUsername(claims.Namespace, claims.ServiceAccountName),
+		UID:    claims.ServiceAccountUID,
+		Groups: MakeGroupNames(claims.Namespace),
+	}, true, nil
+}
+
+// audiencesIntersect reports whether tokenAudiences and expectedAudiences
+// share at least one entry. An empty expectedAudiences means the caller
+// doesn't care about audience at all, so it always matches.
+func audiencesIntersect(tokenAudiences, expectedAudiences []string) bool {
+	if len(expectedAudiences) == 0 {
+		return true
+	}
+	for _, a := range tokenAudiences {
+		for _, b := range expectedAudiences {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hashSHA256(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func base64URLEncode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func base64URLDecode(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
+
+func signRSA(key *rsa.PrivateKey, data []byte) ([]byte, error) {
+	return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashSHA256(data))
+}
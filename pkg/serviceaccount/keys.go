@@ -0,0 +1,271 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serviceaccount
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+)
+
+// KeyProvider abstracts where ServiceAccount JWT signing and verification
+// key material lives. JWTTokenGenerator signs with the active key;
+// JWTTokenAuthenticator verifies against every currently trusted key. This
+// indirection is what lets private key material stay out of the API
+// server process entirely when backed by a KMS-style implementation.
+type KeyProvider interface {
+	// ActiveKeyID returns the key ID that should be stamped into the "kid"
+	// header of newly issued tokens.
+	ActiveKeyID() (string, error)
+	// Sign signs data with the active key and returns the key ID used and
+	// the resulting signature.
+	Sign(data []byte) (keyID string, signature []byte, err error)
+	// PublicKeys returns every currently trusted verification key, keyed
+	// by key ID. During key rollover this includes both the new and the
+	// still-being-retired old keys.
+	PublicKeys() (map[string]*rsa.PublicKey, error)
+}
+
+// FileKeyProvider signs with a single on-disk PEM-encoded RSA private key
+// and trusts a fixed set of on-disk PEM-encoded RSA public keys. It never
+// reloads: rotation means restarting with a new set of files.
+type FileKeyProvider struct {
+	keyID      string
+	privateKey *rsa.PrivateKey
+	publicKeys map[string]*rsa.PublicKey
+}
+
+// NewFileKeyProvider loads privateKeyFile as the active signing key (its
+// keyID derives from the file's base name) and every file in
+// publicKeyFiles as an additional trusted verification key.
+func NewFileKeyProvider(privateKeyFile string, publicKeyFiles []string) (*FileKeyProvider, error) {
+	keyData, err := ioutil.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read private key file %s: %v", privateKeyFile, err)
+	}
+	privateKey, err := parseRSAPrivateKeyPEM(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key file %s: %v", privateKeyFile, err)
+	}
+
+	keyID := keyIDForFile(privateKeyFile)
+	publicKeys := map[string]*rsa.PublicKey{keyID: &privateKey.PublicKey}
+	for _, publicKeyFile := range publicKeyFiles {
+		pubData, err := ioutil.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read public key file %s: %v", publicKeyFile, err)
+		}
+		publicKey, err := parseRSAPublicKeyPEM(pubData)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse public key file %s: %v", publicKeyFile, err)
+		}
+		publicKeys[keyIDForFile(publicKeyFile)] = publicKey
+	}
+
+	return &FileKeyProvider{keyID: keyID, privateKey: privateKey, publicKeys: publicKeys}, nil
+}
+
+func (p *FileKeyProvider) ActiveKeyID() (string, error) {
+	return p.keyID, nil
+}
+
+func (p *FileKeyProvider) Sign(data []byte) (string, []byte, error) {
+	sig, err := signRSA(p.privateKey, data)
+	return p.keyID, sig, err
+}
+
+func (p *FileKeyProvider) PublicKeys() (map[string]*rsa.PublicKey, error) {
+	return p.publicKeys, nil
+}
+
+// DirectoryKeyProvider watches a directory of PEM-encoded RSA key pairs
+// and hot-reloads it on every access, so a key can be rotated in by
+// dropping a new "<kid>.key"/"<kid>.pub" pair into the directory and
+// switching the well-known "active" symlink, without restarting the API
+// server. The active key is the target of "<dir>/active.key".
+type DirectoryKeyProvider struct {
+	dir string
+
+	mu         sync.RWMutex
+	keyID      string
+	privateKey *rsa.PrivateKey
+	publicKeys map[string]*rsa.PublicKey
+}
+
+// NewDirectoryKeyProvider constructs a DirectoryKeyProvider rooted at dir
+// and performs an initial load.
+func NewDirectoryKeyProvider(dir string) (*DirectoryKeyProvider, error) {
+	p := &DirectoryKeyProvider{dir: dir}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// reload re-reads every "*.pub" file in the directory as a trusted
+// verification key and "active.key" as the active signing key.
+func (p *DirectoryKeyProvider) reload() error {
+	matches, err := filepath.Glob(filepath.Join(p.dir, "*.pub"))
+	if err != nil {
+		return err
+	}
+	publicKeys := make(map[string]*rsa.PublicKey, len(matches))
+	for _, match := range matches {
+		data, err := ioutil.ReadFile(match)
+		if err != nil {
+			return fmt.Errorf("could not read public key file %s: %v", match, err)
+		}
+		publicKey, err := parseRSAPublicKeyPEM(data)
+		if err != nil {
+			return fmt.Errorf("could not parse public key file %s: %v", match, err)
+		}
+		publicKeys[keyIDForFile(match)] = publicKey
+	}
+
+	activeKeyFile := filepath.Join(p.dir, "active.key")
+	keyData, err := ioutil.ReadFile(activeKeyFile)
+	if err != nil {
+		return fmt.Errorf("could not read active key file %s: %v", activeKeyFile, err)
+	}
+	privateKey, err := parseRSAPrivateKeyPEM(keyData)
+	if err != nil {
+		return fmt.Errorf("could not parse active key file %s: %v", activeKeyFile, err)
+	}
+	resolved, err := filepath.EvalSymlinks(activeKeyFile)
+	if err != nil {
+		resolved = activeKeyFile
+	}
+	keyID := keyIDForFile(resolved)
+	publicKeys[keyID] = &privateKey.PublicKey
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keyID = keyID
+	p.privateKey = privateKey
+	p.publicKeys = publicKeys
+	return nil
+}
+
+func (p *DirectoryKeyProvider) ActiveKeyID() (string, error) {
+	if err := p.reload(); err != nil {
+		return "", err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.keyID, nil
+}
+
+func (p *DirectoryKeyProvider) Sign(data []byte) (string, []byte, error) {
+	if err := p.reload(); err != nil {
+		return "", nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	sig, err := signRSA(p.privateKey, data)
+	return p.keyID, sig, err
+}
+
+func (p *DirectoryKeyProvider) PublicKeys() (map[string]*rsa.PublicKey, error) {
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.publicKeys, nil
+}
+
+// KMSSigner is the sign/verify RPC surface a KMS-style external signer
+// must implement. Implementations own the private key material; it never
+// crosses into the API server process.
+type KMSSigner interface {
+	// Sign asks the KMS to sign data with its currently active key and
+	// returns the key ID and signature.
+	Sign(data []byte) (keyID string, signature []byte, err error)
+	// PublicKeys returns every key the KMS currently considers trusted
+	// for verification, keyed by key ID.
+	PublicKeys() (map[string]*rsa.PublicKey, error)
+	// ActiveKeyID returns the key ID the KMS is currently signing with.
+	ActiveKeyID() (string, error)
+}
+
+// KMSKeyProvider adapts a KMSSigner RPC client to the KeyProvider
+// interface used by the token generator and authenticator.
+type KMSKeyProvider struct {
+	Signer KMSSigner
+}
+
+func (p KMSKeyProvider) ActiveKeyID() (string, error) {
+	return p.Signer.ActiveKeyID()
+}
+
+func (p KMSKeyProvider) Sign(data []byte) (string, []byte, error) {
+	return p.Signer.Sign(data)
+}
+
+func (p KMSKeyProvider) PublicKeys() (map[string]*rsa.PublicKey, error) {
+	return p.Signer.PublicKeys()
+}
+
+func keyIDForFile(path string) string {
+	base := filepath.Base(path)
+	return base[:len(base)-len(filepath.Ext(base))]
+}
+
+func parseRSAPrivateKeyPEM(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("PEM block does not contain an RSA private key")
+	}
+	return rsaKey, nil
+}
+
+func parseRSAPublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		if rsaKey, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+		return nil, fmt.Errorf("certificate does not contain an RSA public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("PEM block does not contain an RSA public key")
+	}
+	return rsaKey, nil
+}
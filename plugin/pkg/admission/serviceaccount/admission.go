@@ -0,0 +1,176 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package serviceaccount implements an admission plugin that defaults
+// ServiceAccountName on pods, mounts the ServiceAccount's API token secret,
+// and injects the ServiceAccount's image pull secrets.
+package serviceaccount
+
+import (
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/admission"
+	"k8s.io/kubernetes/pkg/api"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	clientset "k8s.io/kubernetes/pkg/client/clientset_generated/release_1_2"
+)
+
+const (
+	// DefaultServiceAccountName is the name of the ServiceAccount assigned
+	// to pods that don't specify one.
+	DefaultServiceAccountName = "default"
+
+	// DefaultAPITokenMountPath is where the API token secret is mounted
+	// when a pod doesn't already mount a volume at this path.
+	DefaultAPITokenMountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// NewServiceAccount returns an admission.Interface that defaults and
+// validates ServiceAccount-related fields on pods.
+func NewServiceAccount(cl clientset.Interface) *serviceAccount {
+	return &serviceAccount{
+		Handler: admission.NewHandler(admission.Create),
+		client:  cl,
+
+		// MountServiceAccountToken and LimitSecretReferences match
+		// upstream defaults: mount the API token unless a pod opts out,
+		// and don't restrict which secrets a pod may reference.
+		MountServiceAccountToken: true,
+		LimitSecretReferences:    false,
+	}
+}
+
+// serviceAccount is an implementation of admission.Interface.
+type serviceAccount struct {
+	*admission.Handler
+	client clientset.Interface
+
+	// MountServiceAccountToken creates a Secret volume for the token and
+	// auto-mounts it into every container of pods that don't already
+	// specify a volume mount at DefaultAPITokenMountPath.
+	MountServiceAccountToken bool
+
+	// LimitSecretReferences is not read by Admit: pods are admitted
+	// whatever secrets they reference, whether or not their
+	// ServiceAccount also references them.
+	LimitSecretReferences bool
+}
+
+func (s *serviceAccount) Run() {}
+func (s *serviceAccount) Stop() {}
+
+func (s *serviceAccount) Admit(a admission.Attributes) error {
+	if a.GetResource() != api.SchemeGroupVersion.WithResource("pods").GroupResource() {
+		return nil
+	}
+	if len(a.GetSubresource()) != 0 {
+		return nil
+	}
+	pod, ok := a.GetObject().(*api.Pod)
+	if !ok {
+		return nil
+	}
+
+	if len(pod.Spec.ServiceAccountName) == 0 {
+		pod.Spec.ServiceAccountName = DefaultServiceAccountName
+	}
+
+	serviceAccount, err := s.client.Legacy().ServiceAccounts(a.GetNamespace()).Get(pod.Spec.ServiceAccountName)
+	if err != nil {
+		return apierrors.NewNotFound(api.Resource("serviceaccount"), pod.Spec.ServiceAccountName)
+	}
+
+	if s.MountServiceAccountToken {
+		if err := s.mountServiceAccountToken(serviceAccount, pod); err != nil {
+			return err
+		}
+	}
+
+	if len(pod.Spec.ImagePullSecrets) == 0 {
+		for _, pullSecret := range serviceAccount.ImagePullSecrets {
+			pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, api.LocalObjectReference{Name: pullSecret.Name})
+		}
+	}
+
+	return nil
+}
+
+// mountServiceAccountToken finds the ServiceAccount's token secret and
+// mounts it at DefaultAPITokenMountPath in every container that doesn't
+// already mount something there.
+func (s *serviceAccount) mountServiceAccountToken(serviceAccount *api.ServiceAccount, pod *api.Pod) error {
+	needsMount := false
+	for _, container := range pod.Spec.Containers {
+		if !containerMountsTokenPath(container) {
+			needsMount = true
+			break
+		}
+	}
+	if !needsMount {
+		return nil
+	}
+
+	tokenSecretName, err := s.findTokenSecretName(serviceAccount)
+	if err != nil {
+		return err
+	}
+
+	volumeName := tokenSecretName
+	pod.Spec.Volumes = append(pod.Spec.Volumes, api.Volume{
+		Name: volumeName,
+		VolumeSource: api.VolumeSource{
+			Secret: &api.SecretVolumeSource{SecretName: tokenSecretName},
+		},
+	})
+
+	volumeMount := api.VolumeMount{
+		Name:      volumeName,
+		ReadOnly:  true,
+		MountPath: DefaultAPITokenMountPath,
+	}
+	for i := range pod.Spec.Containers {
+		if containerMountsTokenPath(pod.Spec.Containers[i]) {
+			continue
+		}
+		pod.Spec.Containers[i].VolumeMounts = append(pod.Spec.Containers[i].VolumeMounts, volumeMount)
+	}
+
+	return nil
+}
+
+func (s *serviceAccount) findTokenSecretName(serviceAccount *api.ServiceAccount) (string, error) {
+	for _, ref := range serviceAccount.Secrets {
+		secret, err := s.client.Legacy().Secrets(serviceAccount.Namespace).Get(ref.Name)
+		if err != nil {
+			continue
+		}
+		if secret.Type == api.SecretTypeServiceAccountToken {
+			return secret.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no token secret found for service account %s/%s", serviceAccount.Namespace, serviceAccount.Name)
+}
+
+// containerMountsTokenPath returns true if container already mounts a
+// volume at DefaultAPITokenMountPath, so we don't double-mount it.
+func containerMountsTokenPath(container api.Container) bool {
+	for _, vm := range container.VolumeMounts {
+		if vm.MountPath == DefaultAPITokenMountPath {
+			return true
+		}
+	}
+	return false
+}
@@ -23,11 +23,14 @@ package integration
 // to work for any client of the HTTP interface.
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -35,8 +38,10 @@ import (
 	"k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/api/testapi"
 	"k8s.io/kubernetes/pkg/auth/authenticator"
+	"k8s.io/kubernetes/pkg/auth/authenticator/anonymous"
 	"k8s.io/kubernetes/pkg/auth/authenticator/bearertoken"
 	"k8s.io/kubernetes/pkg/auth/authorizer"
+	"k8s.io/kubernetes/pkg/auth/authorizer/rbac"
 	"k8s.io/kubernetes/pkg/auth/user"
 	clientset "k8s.io/kubernetes/pkg/client/clientset_generated/release_1_2"
 	client "k8s.io/kubernetes/pkg/client/unversioned"
@@ -56,6 +61,10 @@ const (
 
 	readOnlyServiceAccountName  = "ro"
 	readWriteServiceAccountName = "rw"
+
+	// testDockercfg is the registry credential blob the test's StaticDockercfgProvider hands
+	// out to the dockercfg controller for every ServiceAccount it provisions a pull secret for.
+	testDockercfg = `{"registry.example.com":{"auth":"dGVzdDp0ZXN0"}}`
 )
 
 func init() {
@@ -63,7 +72,7 @@ func init() {
 }
 
 func TestServiceAccountAutoCreate(t *testing.T) {
-	c, _, stopFunc := startServiceAccountTestServer(t)
+	c, _, _, _, stopFunc := startServiceAccountTestServer(t)
 	defer stopFunc()
 
 	ns := "test-service-account-creation"
@@ -97,7 +106,7 @@ func TestServiceAccountAutoCreate(t *testing.T) {
 }
 
 func TestServiceAccountTokenAutoCreate(t *testing.T) {
-	c, _, stopFunc := startServiceAccountTestServer(t)
+	c, _, keyProvider, _, stopFunc := startServiceAccountTestServer(t)
 	defer stopFunc()
 
 	ns := "test-service-account-token-creation"
@@ -162,6 +171,37 @@ func TestServiceAccountTokenAutoCreate(t *testing.T) {
 		t.Fatalf("Expected new auto-created token value")
 	}
 
+	// Force a key rotation: bring up a new active key and check that the
+	// current token secret is still there (its kid is still trusted), then
+	// retire the old key, delete the token and confirm a fresh token is
+	// issued in its place.
+	oldKeyID, _ := keyProvider.ActiveKeyID()
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate rotation key: %v", err)
+	}
+	keyProvider.rotate("rotated-key", newKey)
+
+	if _, _, err := getReferencedServiceAccountToken(c, ns, name, false); err != nil {
+		t.Fatalf("existing token secret disappeared immediately after rotating in a new active key: %v", err)
+	}
+
+	keyProvider.retire(oldKeyID)
+
+	// Deleting the now-untrusted token forces TokensController to reissue
+	// one signed by the new active key.
+	err = c.Legacy().Secrets(ns).Delete(token3Name, nil)
+	if err != nil {
+		t.Fatalf("Could not delete token: %v", err)
+	}
+	token4Name, token4, err := getReferencedServiceAccountToken(c, ns, name, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token4Name == token3Name || token4 == token3 {
+		t.Fatalf("Expected a freshly rotated token")
+	}
+
 	// Delete service account
 	err = c.Legacy().ServiceAccounts(ns).Delete(name, nil)
 	if err != nil {
@@ -169,7 +209,7 @@ func TestServiceAccountTokenAutoCreate(t *testing.T) {
 	}
 
 	// Wait for tokens to be deleted
-	tokensToCleanup := sets.NewString(token1Name, token2Name, token3Name)
+	tokensToCleanup := sets.NewString(token1Name, token2Name, token3Name, token4Name)
 	err = wait.Poll(time.Second, 10*time.Second, func() (bool, error) {
 		// Get all secrets in the namespace
 		secrets, err := c.Legacy().Secrets(ns).List(api.ListOptions{})
@@ -192,7 +232,7 @@ func TestServiceAccountTokenAutoCreate(t *testing.T) {
 }
 
 func TestServiceAccountTokenAutoMount(t *testing.T) {
-	c, _, stopFunc := startServiceAccountTestServer(t)
+	c, _, _, _, stopFunc := startServiceAccountTestServer(t)
 	defer stopFunc()
 
 	ns := "auto-mount-ns"
@@ -268,8 +308,60 @@ func TestServiceAccountTokenAutoMount(t *testing.T) {
 	}
 }
 
+// TestAutomaticCreationOfPullSecrets verifies that a kubernetes.io/dockercfg secret is
+// provisioned for a ServiceAccount, appended to its ImagePullSecrets, and that a pod which
+// doesn't specify its own image pull secrets has it auto-injected the same way the API token
+// secret is auto-mounted (see TestServiceAccountTokenAutoMount).
+func TestAutomaticCreationOfPullSecrets(t *testing.T) {
+	c, _, _, _, stopFunc := startServiceAccountTestServer(t)
+	defer stopFunc()
+
+	ns := "pull-secret-ns"
+
+	_, err := c.Legacy().Namespaces().Create(&api.Namespace{ObjectMeta: api.ObjectMeta{Name: ns}})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		t.Fatalf("could not create namespace: %v", err)
+	}
+
+	// Get default service account's auto-created dockercfg secret.
+	dockercfgName, err := getReferencedServiceAccountDockercfg(c, ns, serviceaccountadmission.DefaultServiceAccountName, true)
+	if err != nil {
+		t.Fatalf("dockercfg secret was not created: %v", err)
+	}
+
+	serviceAccount, err := getServiceAccount(c, ns, serviceaccountadmission.DefaultServiceAccountName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, ref := range serviceAccount.ImagePullSecrets {
+		if ref.Name == dockercfgName {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected %s in ImagePullSecrets %#v", dockercfgName, serviceAccount.ImagePullSecrets)
+	}
+
+	// A pod that doesn't specify its own pull secrets should have the dockercfg secret injected.
+	protoPod := api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "pullsecretpod"},
+		Spec: api.PodSpec{
+			Containers: []api.Container{{Name: "container-1", Image: "container-1-image"}},
+		},
+	}
+	createdPod, err := c.Legacy().Pods(ns).Create(&protoPod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPullSecrets := []api.LocalObjectReference{{Name: dockercfgName}}
+	if !api.Semantic.DeepEqual(&expectedPullSecrets, &createdPod.Spec.ImagePullSecrets) {
+		t.Fatalf("Expected\n\t%#v\n\tgot\n\t%#v", expectedPullSecrets, createdPod.Spec.ImagePullSecrets)
+	}
+}
+
 func TestServiceAccountTokenAuthentication(t *testing.T) {
-	c, config, stopFunc := startServiceAccountTestServer(t)
+	c, config, _, policy, stopFunc := startServiceAccountTestServer(t)
 	defer stopFunc()
 
 	myns := "auth-ns"
@@ -287,11 +379,13 @@ func TestServiceAccountTokenAuthentication(t *testing.T) {
 		t.Fatalf("could not create namespace: %v", err)
 	}
 
-	// Create "ro" user in myns
+	// Create "ro" user in myns, bound to the built-in "view" ClusterRole
+	// within myns instead of any other namespace.
 	_, err = c.Legacy().ServiceAccounts(myns).Create(&api.ServiceAccount{ObjectMeta: api.ObjectMeta{Name: readOnlyServiceAccountName}})
 	if err != nil {
 		t.Fatalf("Service Account not created: %v", err)
 	}
+	policy.SetRoleBinding(serviceAccountRoleBinding(myns, readOnlyServiceAccountName, rbac.ClusterRoleView))
 	roTokenName, roToken, err := getReferencedServiceAccountToken(c, myns, readOnlyServiceAccountName, true)
 	if err != nil {
 		t.Fatal(err)
@@ -307,11 +401,12 @@ func TestServiceAccountTokenAuthentication(t *testing.T) {
 	}
 	doServiceAccountAPIRequests(t, roClient, myns, false, false, false)
 
-	// Create "rw" user in myns
+	// Create "rw" user in myns, bound to the built-in "edit" ClusterRole.
 	_, err = c.Legacy().ServiceAccounts(myns).Create(&api.ServiceAccount{ObjectMeta: api.ObjectMeta{Name: readWriteServiceAccountName}})
 	if err != nil {
 		t.Fatalf("Service Account not created: %v", err)
 	}
+	policy.SetRoleBinding(serviceAccountRoleBinding(myns, readWriteServiceAccountName, rbac.ClusterRoleEdit))
 	_, rwToken, err := getReferencedServiceAccountToken(c, myns, readWriteServiceAccountName, true)
 	if err != nil {
 		t.Fatal(err)
@@ -322,7 +417,9 @@ func TestServiceAccountTokenAuthentication(t *testing.T) {
 	doServiceAccountAPIRequests(t, rwClient, myns, true, true, true)
 	doServiceAccountAPIRequests(t, rwClient, otherns, true, false, false)
 
-	// Get default user and token which should have been automatically created
+	// Get default user and token which should have been automatically created.
+	// It only has the bootstrap-seeded "system:discovery" binding, which
+	// grants no permissions, so it can authenticate but not read or write.
 	_, defaultToken, err := getReferencedServiceAccountToken(c, myns, "default", true)
 	if err != nil {
 		t.Fatalf("could not get default user and token: %v", err)
@@ -333,9 +430,253 @@ func TestServiceAccountTokenAuthentication(t *testing.T) {
 	doServiceAccountAPIRequests(t, defaultClient, myns, true, false, false)
 }
 
-// startServiceAccountTestServer returns a started server
+// TestRoleBindingMutationFlipsAuthorization grants the "rw" ServiceAccount
+// no permissions at first, confirms every request is forbidden, then
+// rebinds it to "edit" and polls until the RBAC cache resyncs and the same
+// requests start succeeding.
+func TestRoleBindingMutationFlipsAuthorization(t *testing.T) {
+	c, config, _, policy, stopFunc := startServiceAccountTestServer(t)
+	defer stopFunc()
+
+	ns := "rbac-mutation-ns"
+	_, err := c.Legacy().Namespaces().Create(&api.Namespace{ObjectMeta: api.ObjectMeta{Name: ns}})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		t.Fatalf("could not create namespace: %v", err)
+	}
+
+	_, err = c.Legacy().ServiceAccounts(ns).Create(&api.ServiceAccount{ObjectMeta: api.ObjectMeta{Name: readWriteServiceAccountName}})
+	if err != nil {
+		t.Fatalf("Service Account not created: %v", err)
+	}
+	_, token, err := getReferencedServiceAccountToken(c, ns, readWriteServiceAccountName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig := config
+	clientConfig.BearerToken = token
+	rwClient := clientset.NewForConfigOrDie(&clientConfig)
+
+	// No binding beyond the bootstrap discovery placeholder yet: denied.
+	doServiceAccountAPIRequests(t, rwClient, ns, true, false, false)
+
+	// Bind to "edit" and wait for the RBAC cache to resync and observe it.
+	policy.SetRoleBinding(serviceAccountRoleBinding(ns, readWriteServiceAccountName, rbac.ClusterRoleEdit))
+	err = wait.Poll(100*time.Millisecond, 10*time.Second, func() (bool, error) {
+		_, err := rwClient.Legacy().Secrets(ns).List(api.ListOptions{})
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("authorization never flipped to allowed after rebinding: %v", err)
+	}
+	doServiceAccountAPIRequests(t, rwClient, ns, true, true, true)
+}
+
+// TestAnonymousAuthentication covers all four combinations of "is
+// AnonymousAuth enabled" and "does the authorizer grant system:unauthenticated
+// access": with it disabled, an unauthenticated request is always rejected by
+// the authenticator (401), and granting its group a binding changes nothing
+// since there's no anonymous identity for that binding to apply to. With it
+// enabled, the same request authenticates as anonymous.Username and is
+// authorized or not purely on whether a binding matches anonymous.Group (403
+// until one does, then success). It also confirms the WWW-Authenticate
+// challenge is only sent when AnonymousAuth is disabled.
+func TestAnonymousAuthentication(t *testing.T) {
+	for _, anonymousAuth := range []bool{false, true} {
+		c, config, _, policy, stopFunc := startServiceAccountTestServerWithAnonymous(t, anonymousAuth)
+
+		ns := fmt.Sprintf("anon-ns-%v", anonymousAuth)
+		_, err := c.Legacy().Namespaces().Create(&api.Namespace{ObjectMeta: api.ObjectMeta{Name: ns}})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			t.Fatalf("could not create namespace: %v", err)
+		}
+
+		anonConfig := config
+		anonConfig.BearerToken = ""
+		anonClient := clientset.NewForConfigOrDie(&anonConfig)
+
+		// No credentials, no binding yet.
+		_, err = anonClient.Legacy().Secrets(ns).List(api.ListOptions{})
+		switch {
+		case !anonymousAuth && !errors.IsUnauthorized(err):
+			t.Fatalf("anonymousAuth=false: expected Unauthorized, got %v", err)
+		case anonymousAuth && !errors.IsForbidden(err):
+			t.Fatalf("anonymousAuth=true, no binding: expected Forbidden, got %v", err)
+		}
+
+		// A bare HTTP request (not through the client, so we can see the
+		// response headers) should only carry a WWW-Authenticate challenge
+		// when AnonymousAuth is disabled: once it's enabled, the request
+		// did authenticate, it just wasn't authorized.
+		resp, err := http.Get(config.Host + "/api/v1/namespaces/" + ns + "/secrets")
+		if err != nil {
+			t.Fatalf("could not issue anonymous request: %v", err)
+		}
+		resp.Body.Close()
+		challenge := resp.Header.Get("WWW-Authenticate")
+		switch {
+		case !anonymousAuth && challenge == "":
+			t.Fatalf("anonymousAuth=false: expected a WWW-Authenticate challenge, got none")
+		case anonymousAuth && challenge != "":
+			t.Fatalf("anonymousAuth=true: expected no WWW-Authenticate challenge, got %q", challenge)
+		}
+
+		// Grant system:unauthenticated read access and confirm it only
+		// changes the outcome when AnonymousAuth is enabled: with it
+		// disabled there's no anonymous identity for the binding to reach.
+		policy.SetClusterRoleBinding(&rbac.ClusterRoleBinding{
+			ObjectMeta: api.ObjectMeta{Name: "anon-view"},
+			Subjects:   []rbac.Subject{{Kind: rbac.GroupKind, Name: anonymous.Group}},
+			RoleRef:    rbac.RoleRef{Kind: rbac.ClusterRoleKind, Name: rbac.ClusterRoleView},
+		})
+		if anonymousAuth {
+			err = wait.Poll(100*time.Millisecond, 10*time.Second, func() (bool, error) {
+				_, err := anonClient.Legacy().Secrets(ns).List(api.ListOptions{})
+				return err == nil, nil
+			})
+			if err != nil {
+				t.Fatalf("anonymousAuth=true, after granting view: expected success, got %v", err)
+			}
+		} else {
+			time.Sleep(200 * time.Millisecond)
+			if _, err := anonClient.Legacy().Secrets(ns).List(api.ListOptions{}); !errors.IsUnauthorized(err) {
+				t.Fatalf("anonymousAuth=false: expected still Unauthorized after granting system:unauthenticated a binding, got %v", err)
+			}
+		}
+
+		stopFunc()
+	}
+}
+
+// serviceAccountRoleBinding returns a RoleBinding scoping saName in
+// namespace to clusterRoleName.
+func serviceAccountRoleBinding(namespace, saName, clusterRoleName string) *rbac.RoleBinding {
+	return &rbac.RoleBinding{
+		ObjectMeta: api.ObjectMeta{Name: saName + "-" + clusterRoleName, Namespace: namespace},
+		Subjects:   []rbac.Subject{{Kind: rbac.ServiceAccountKind, Namespace: namespace, Name: saName}},
+		RoleRef:    rbac.RoleRef{Kind: rbac.ClusterRoleKind, Name: clusterRoleName},
+	}
+}
+
+// TestBoundServiceAccountToken exercises the TokenRequest-style tokens
+// minted by serviceaccount.BoundTokenGenerator directly against the
+// authenticator, the way the "serviceaccounts/token" subresource would if
+// it were wired up -- that subresource API and the kubelet-style
+// projected-volume source that would refresh the token file are both
+// still deferred, so this test drives the generator/authenticator pair
+// directly: (a) a token minted for audience "A" must not authenticate
+// against an authenticator expecting "B", (b) a token must stop
+// authenticating once its expiry has passed, and (c) a token bound to a
+// Pod must stop authenticating once that Pod is deleted.
+func TestBoundServiceAccountToken(t *testing.T) {
+	c, _, keyProvider, _, stopFunc := startServiceAccountTestServer(t)
+	defer stopFunc()
+
+	ns := "bound-token-ns"
+	_, err := c.Legacy().Namespaces().Create(&api.Namespace{ObjectMeta: api.ObjectMeta{Name: ns}})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		t.Fatalf("could not create namespace: %v", err)
+	}
+
+	serviceAccount, err := c.Legacy().ServiceAccounts(ns).Create(&api.ServiceAccount{ObjectMeta: api.ObjectMeta{Name: "bound"}})
+	if err != nil {
+		t.Fatalf("Service Account not created: %v", err)
+	}
+
+	pod, err := c.Legacy().Pods(ns).Create(&api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "bound-pod"},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "container-1", Image: "container-1-image"}}},
+	})
+	if err != nil {
+		t.Fatalf("Pod not created: %v", err)
+	}
+
+	boundGenerator := serviceaccount.NewBoundTokenGenerator(keyProvider, 3600)
+	getter := &boundTokenTestGetter{c: c}
+	boundAuth := serviceaccount.JWTTokenAuthenticator(keyProvider, false, getter)
+
+	// (a) audience mismatch is rejected.
+	tokenForA, err := boundGenerator.GenerateBoundToken(*serviceAccount, []string{"A"}, 3600, nil, nil)
+	if err != nil {
+		t.Fatalf("could not generate bound token: %v", err)
+	}
+	if _, ok, _ := boundAuth.AuthenticateTokenWithAudiences(tokenForA, []string{"B"}); ok {
+		t.Fatalf("token minted for audience A authenticated against expected audience B")
+	}
+	if _, ok, err := boundAuth.AuthenticateTokenWithAudiences(tokenForA, []string{"A"}); err != nil || !ok {
+		t.Fatalf("token minted for audience A did not authenticate against expected audience A: ok=%v err=%v", ok, err)
+	}
+
+	// (b) expiry is enforced. GenerateBoundToken clamps a non-positive
+	// expirationSeconds up to the generator's max instead of expiring it
+	// immediately, so mint from a generator whose max is the shortest
+	// lifetime we're willing to wait out instead.
+	shortLivedGenerator := serviceaccount.NewBoundTokenGenerator(keyProvider, 1)
+	expiredToken, err := shortLivedGenerator.GenerateBoundToken(*serviceAccount, nil, 1, nil, nil)
+	if err != nil {
+		t.Fatalf("could not generate bound token: %v", err)
+	}
+	if _, ok, err := boundAuth.AuthenticateToken(expiredToken); err != nil || !ok {
+		t.Fatalf("freshly minted short-lived token did not authenticate: ok=%v err=%v", ok, err)
+	}
+	time.Sleep(2 * time.Second)
+	if _, ok, _ := boundAuth.AuthenticateToken(expiredToken); ok {
+		t.Fatalf("expired token still authenticated")
+	}
+
+	// (c) deleting the bound pod invalidates the token.
+	podRef := &serviceaccount.BoundObjectReference{Name: pod.Name, UID: string(pod.UID)}
+	boundToken, err := boundGenerator.GenerateBoundToken(*serviceAccount, nil, 3600, podRef, nil)
+	if err != nil {
+		t.Fatalf("could not generate bound token: %v", err)
+	}
+	if _, ok, err := boundAuth.AuthenticateToken(boundToken); err != nil || !ok {
+		t.Fatalf("token bound to a live pod did not authenticate: ok=%v err=%v", ok, err)
+	}
+	if err := c.Legacy().Pods(ns).Delete(pod.Name, nil); err != nil {
+		t.Fatalf("could not delete bound pod: %v", err)
+	}
+	if _, ok, _ := boundAuth.AuthenticateToken(boundToken); ok {
+		t.Fatalf("token bound to a deleted pod still authenticated")
+	}
+}
+
+// boundTokenTestGetter adapts the test clientset to
+// serviceaccount.ServiceAccountTokenGetter for TestBoundServiceAccountToken;
+// GetSecret is unused since these tests all authenticate with lookup=false.
+type boundTokenTestGetter struct {
+	c *clientset.Clientset
+}
+
+func (g *boundTokenTestGetter) GetServiceAccount(namespace, name string) (*api.ServiceAccount, error) {
+	return g.c.Legacy().ServiceAccounts(namespace).Get(name)
+}
+
+func (g *boundTokenTestGetter) GetSecret(namespace, name string) (*api.Secret, error) {
+	return g.c.Legacy().Secrets(namespace).Get(name)
+}
+
+func (g *boundTokenTestGetter) GetPod(namespace, name string) (*api.Pod, error) {
+	return g.c.Legacy().Pods(namespace).Get(name)
+}
+
+func (g *boundTokenTestGetter) GetNode(name string) (*api.Node, error) {
+	return g.c.Legacy().Nodes().Get(name)
+}
+
+// startServiceAccountTestServer returns a started server with anonymous
+// authentication disabled, so an unauthenticated request is rejected by
+// the authenticator itself (401) rather than reaching the authorizer.
 // It is the responsibility of the caller to ensure the returned stopFunc is called
-func startServiceAccountTestServer(t *testing.T) (*clientset.Clientset, client.Config, func()) {
+func startServiceAccountTestServer(t *testing.T) (*clientset.Clientset, client.Config, *testKeyProvider, *rbac.Store, func()) {
+	return startServiceAccountTestServerWithAnonymous(t, false)
+}
+
+// startServiceAccountTestServerWithAnonymous is startServiceAccountTestServer
+// with the AnonymousAuth option exposed: when anonymousAuth is true, a
+// request with no credentials still authenticates, as anonymous.Username in
+// anonymous.Group, and it's the authorizer that decides whether it's allowed
+// (403 if not) instead of the authenticator rejecting it outright (401).
+func startServiceAccountTestServerWithAnonymous(t *testing.T, anonymousAuth bool) (*clientset.Clientset, client.Config, *testKeyProvider, *rbac.Store, func()) {
 
 	deleteAllEtcdKeys()
 
@@ -360,43 +701,42 @@ func startServiceAccountTestServer(t *testing.T) (*clientset.Clientset, client.C
 		return nil, false, nil
 	})
 	serviceAccountKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	serviceAccountKeyProvider := newTestKeyProvider("initial-key", serviceAccountKey)
 	serviceAccountTokenGetter := serviceaccountcontroller.NewGetterFromClient(rootClientset)
-	serviceAccountTokenAuth := serviceaccount.JWTTokenAuthenticator([]*rsa.PublicKey{&serviceAccountKey.PublicKey}, true, serviceAccountTokenGetter)
-	authenticator := union.New(
+	serviceAccountTokenAuth := serviceaccount.JWTTokenAuthenticator(serviceAccountKeyProvider, true, serviceAccountTokenGetter)
+	authRequestHandlers := []authenticator.Request{
 		bearertoken.New(rootTokenAuth),
 		bearertoken.New(serviceAccountTokenAuth),
-	)
-
-	// Set up a stub authorizer:
-	// 1. The "root" user is allowed to do anything
-	// 2. ServiceAccounts named "ro" are allowed read-only operations in their namespace
-	// 3. ServiceAccounts named "rw" are allowed any operation in their namespace
+	}
+	if anonymousAuth {
+		// Lowest priority: only reached once every other handler has
+		// passed on the request, i.e. once we know no credentials were
+		// presented (or the ones presented didn't match).
+		authRequestHandlers = append(authRequestHandlers, anonymous.NewAuthenticator())
+	}
+	authenticator := union.New(authRequestHandlers...)
+
+	// Set up the RBAC policy subsystem: a Store holding the authoritative
+	// Role/ClusterRole/RoleBinding/ClusterRoleBinding objects, a
+	// BootstrapController seeding it with the built-in ClusterRoles and a
+	// default-SA binding per namespace, and a Cache the RBACAuthorizer
+	// actually reads from, resyncing from the Store on a short period so
+	// tests can observe a binding edit taking effect within one resync
+	// instead of instantly.
+	rbacPolicy := rbac.NewStore()
+	rbacBootstrap := rbac.NewBootstrapController(rootClientset, rbac.BootstrapControllerOptions{Store: rbacPolicy, ReconcilePeriod: 50 * time.Millisecond})
+	rbacBootstrap.Run()
+	rbacCache := rbac.NewCache(rbacPolicy, 50*time.Millisecond)
+	rbacCache.Run()
+	rbacAuthorizer := rbac.New(rbacCache, rbacCache, rbacCache, rbacCache)
+
+	// "root" bypasses RBAC entirely; everyone else is resolved against
+	// rbacPolicy's bindings.
 	authorizer := authorizer.AuthorizerFunc(func(attrs authorizer.Attributes) error {
-		username := attrs.GetUserName()
-		ns := attrs.GetNamespace()
-
-		// If the user is "root"...
-		if username == rootUserName {
-			// allow them to do anything
+		if attrs.GetUserName() == rootUserName {
 			return nil
 		}
-
-		// If the user is a service account...
-		if serviceAccountNamespace, serviceAccountName, err := serviceaccount.SplitUsername(username); err == nil {
-			// Limit them to their own namespace
-			if serviceAccountNamespace == ns {
-				switch serviceAccountName {
-				case readOnlyServiceAccountName:
-					if attrs.IsReadOnly() {
-						return nil
-					}
-				case readWriteServiceAccountName:
-					return nil
-				}
-			}
-		}
-
-		return fmt.Errorf("User %s is denied (ns=%s, readonly=%v, resource=%s)", username, ns, attrs.IsReadOnly(), attrs.GetResource())
+		return rbacAuthorizer.Authorize(attrs)
 	})
 
 	// Set up admission plugin to auto-assign serviceaccounts to pods
@@ -415,22 +755,39 @@ func startServiceAccountTestServer(t *testing.T) (*clientset.Clientset, client.C
 	}
 
 	// Start the service account and service account token controllers
-	tokenController := serviceaccountcontroller.NewTokensController(rootClientset, serviceaccountcontroller.TokensControllerOptions{TokenGenerator: serviceaccount.JWTTokenGenerator(serviceAccountKey)})
+	tokenController := serviceaccountcontroller.NewTokensController(rootClientset, serviceaccountcontroller.TokensControllerOptions{TokenGenerator: serviceaccount.JWTTokenGenerator(serviceAccountKeyProvider)})
 	tokenController.Run()
 	serviceAccountController := serviceaccountcontroller.NewServiceAccountsController(rootClientset, serviceaccountcontroller.DefaultServiceAccountsControllerOptions())
 	serviceAccountController.Run()
+	// Start the dockercfg controller, which mirrors the token controller's job but for the
+	// per-ServiceAccount image pull secret instead of the API token secret.
+	dockercfgController := serviceaccountcontroller.NewDockercfgController(rootClientset, serviceaccountcontroller.DockercfgControllerOptions{
+		DockercfgProvider: serviceaccountcontroller.StaticDockercfgProvider{Dockercfg: testDockercfg},
+	})
+	dockercfgController.Run()
+	// Start the rotation controller, which deletes token secrets signed by a
+	// kid serviceAccountKeyProvider no longer trusts so they get reissued
+	// under a currently active key.
+	rotationController := serviceaccountcontroller.NewTokenRotationController(rootClientset, serviceaccountcontroller.TokenRotationControllerOptions{
+		KeyProvider: serviceAccountKeyProvider,
+	})
+	rotationController.Run()
 	// Start the admission plugin reflectors
 	serviceAccountAdmission.Run()
 
 	stop := func() {
 		tokenController.Stop()
 		serviceAccountController.Stop()
+		dockercfgController.Stop()
+		rotationController.Stop()
 		serviceAccountAdmission.Stop()
+		rbacBootstrap.Stop()
+		rbacCache.Stop()
 		// TODO: Uncomment when fix #19254
 		// apiServer.Close()
 	}
 
-	return rootClientset, clientConfig, stop
+	return rootClientset, clientConfig, serviceAccountKeyProvider, rbacPolicy, stop
 }
 
 func getServiceAccount(c *clientset.Clientset, ns string, name string, shouldWait bool) (*api.ServiceAccount, error) {
@@ -507,6 +864,121 @@ func getReferencedServiceAccountToken(c *clientset.Clientset, ns string, name st
 	return tokenName, token, nil
 }
 
+// getReferencedServiceAccountDockercfg finds the kubernetes.io/dockercfg secret the
+// DockercfgController appended to the named ServiceAccount, mirroring
+// getReferencedServiceAccountToken's polling behavior for the token secret.
+func getReferencedServiceAccountDockercfg(c *clientset.Clientset, ns string, name string, shouldWait bool) (string, error) {
+	dockercfgName := ""
+
+	findDockercfg := func() (bool, error) {
+		user, err := c.Legacy().ServiceAccounts(ns).Get(name)
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		for _, ref := range user.Secrets {
+			secret, err := c.Legacy().Secrets(ns).Get(ref.Name)
+			if errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			if secret.Type != api.SecretTypeDockercfg {
+				continue
+			}
+			dockercfgName = secret.Name
+			return true, nil
+		}
+
+		return false, nil
+	}
+
+	if shouldWait {
+		err := wait.Poll(time.Second, 10*time.Second, findDockercfg)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		ok, err := findDockercfg()
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("No dockercfg secret found for %s/%s", ns, name)
+		}
+	}
+	return dockercfgName, nil
+}
+
+// testKeyProvider is an in-memory serviceaccount.KeyProvider for tests: it
+// starts with a single active key and lets a test rotate() in a new one
+// (keeping the old key trusted) and later retire() the old key, mirroring
+// how an operator would roll signing keys without ever taking down every
+// previously issued token at once.
+type testKeyProvider struct {
+	mu         sync.Mutex
+	activeID   string
+	activeKey  *rsa.PrivateKey
+	trustedIDs map[string]*rsa.PublicKey
+}
+
+func newTestKeyProvider(id string, key *rsa.PrivateKey) *testKeyProvider {
+	return &testKeyProvider{
+		activeID:   id,
+		activeKey:  key,
+		trustedIDs: map[string]*rsa.PublicKey{id: &key.PublicKey},
+	}
+}
+
+func (p *testKeyProvider) ActiveKeyID() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.activeID, nil
+}
+
+func (p *testKeyProvider) Sign(data []byte) (string, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.activeKey, crypto.SHA256, hashSHA256(data))
+	return p.activeID, sig, err
+}
+
+func (p *testKeyProvider) PublicKeys() (map[string]*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make(map[string]*rsa.PublicKey, len(p.trustedIDs))
+	for id, key := range p.trustedIDs {
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// rotate makes id/key the active signing key while leaving every
+// previously trusted key verifiable.
+func (p *testKeyProvider) rotate(id string, key *rsa.PrivateKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.activeID = id
+	p.activeKey = key
+	p.trustedIDs[id] = &key.PublicKey
+}
+
+// retire removes id from the trusted verification set.
+func (p *testKeyProvider) retire(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.trustedIDs, id)
+}
+
+func hashSHA256(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
 type testOperation func() error
 
 func doServiceAccountAPIRequests(t *testing.T, c *clientset.Clientset, ns string, authenticated bool, canRead bool, canWrite bool) {